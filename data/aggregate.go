@@ -0,0 +1,295 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/orm"
+	"github.com/stephenafamo/scan"
+)
+
+// ErrKeysetAggregate is returned by ApplyAggregate and AggregateList for
+// params carrying a cursor. Aggregate results are paged by offset.
+var ErrKeysetAggregate = errors.New("data: aggregates do not support keyset pagination")
+
+// AggFunc is an aggregate function clients may request with agg=.
+type AggFunc string
+
+const (
+	AggCount AggFunc = "count" // count(*), or count(column) of non-NULL values
+	AggSum   AggFunc = "sum"   // numeric columns only
+	AggAvg   AggFunc = "avg"   // numeric columns only
+	AggMin   AggFunc = "min"
+	AggMax   AggFunc = "max"
+)
+
+// TruncUnits are the units a time column can be truncated to when grouping,
+// as in group_by=completed_at:day.
+var TruncUnits = []string{"hour", "day", "week", "month", "quarter", "year"}
+
+// GroupKey is a column to group by, optionally truncated to one of
+// TruncUnits.
+type GroupKey struct {
+	Column string
+	Trunc  string
+
+	// As names the result column, defaults to Column. Schema.Resolve sets
+	// it to the public name.
+	As string
+}
+
+// Aggregate is an aggregate computed per group.
+type Aggregate struct {
+	Func   AggFunc
+	Column string // empty for count(*)
+
+	// As names the result column, defaults to func_column, e.g. "sum_duration".
+	As string
+}
+
+// Alias returns the name of the group in result rows.
+func (g GroupKey) Alias() string {
+	if g.As != "" {
+		return g.As
+	}
+	return aliasName(g.Column)
+}
+
+// Alias returns the name of the aggregate in result rows.
+func (a Aggregate) Alias() string {
+	switch {
+	case a.As != "":
+		return a.As
+	case a.Column == "":
+		return string(a.Func)
+	default:
+		return string(a.Func) + "_" + aliasName(a.Column)
+	}
+}
+
+func aliasName(column string) string {
+	return strings.NewReplacer(PathSep, "_", ".", "_").Replace(column)
+}
+
+// Aggregated reports whether params ask for grouped or aggregated rows.
+func (p *QueryParams) Aggregated() bool {
+	return len(p.GroupBy) > 0 || len(p.Aggregates) > 0
+}
+
+// Aliases returns the result column names of the groups and aggregates,
+// the only names aggregate results can be sorted by.
+func (p *QueryParams) Aliases() []string {
+	aliases := make([]string, 0, len(p.GroupBy)+len(p.Aggregates))
+	for _, g := range p.GroupBy {
+		aliases = append(aliases, g.Alias())
+	}
+	for _, a := range p.Aggregates {
+		aliases = append(aliases, a.Alias())
+	}
+	return aliases
+}
+
+// GroupColumn resolves a public name to its real column if it is groupable.
+// Columns of to-many relations cannot be grouped by.
+func (s *Schema) GroupColumn(name string) (string, bool) {
+	ref, ok := s.lookupRef(name)
+	if !ok || !ref.column.Groupable || ref.many() {
+		return "", false
+	}
+	return s.qualify(ref), true
+}
+
+// CheckGroup validates g against the schema: the column must be groupable
+// and only time and date columns can be truncated.
+func (s *Schema) CheckGroup(g GroupKey) error {
+	if _, ok := s.GroupColumn(g.Column); !ok {
+		return fmt.Errorf("column %q is not groupable", g.Column)
+	}
+	if c, _ := s.Lookup(g.Column); g.Trunc != "" && c.Type != TypeTime && c.Type != TypeDate {
+		return fmt.Errorf("column %q cannot be truncated to a %s", g.Column, g.Trunc)
+	}
+	return nil
+}
+
+// CheckAggregate validates a against the schema: the column must be
+// aggregatable, and numeric for sum and avg. count(*) is always allowed.
+func (s *Schema) CheckAggregate(a Aggregate) error {
+	if a.Column == "" {
+		return nil
+	}
+	ref, ok := s.lookupRef(a.Column)
+	if !ok || !ref.column.Aggregatable || ref.many() {
+		return fmt.Errorf("column %q cannot be aggregated", a.Column)
+	}
+	if (a.Func == AggSum || a.Func == AggAvg) && ref.column.Type != TypeInt && ref.column.Type != TypeFloat {
+		return fmt.Errorf("%s needs a numeric column, %q is not", a.Func, a.Column)
+	}
+	return nil
+}
+
+// AggregateRow is a row of an aggregate query, keyed by the aliases of its
+// groups and aggregates.
+type AggregateRow map[string]any
+
+func aggregateRows(ctx context.Context, cols []string) (scan.BeforeFunc, func(any) (AggregateRow, error)) {
+	before, after := scan.MapMapper[any](ctx, cols)
+	return before, func(link any) (AggregateRow, error) {
+		row, err := after(link)
+		return AggregateRow(row), err
+	}
+}
+
+// ApplyAggregate turns q into a GROUP BY query over params.GroupBy that
+// selects the groups and params.Aggregates (count(*) when none are given).
+// Filters, search and relation joins apply as in ApplyAll; sort keys name
+// result columns (see Aliases) and limit and offset apply to the groups.
+// Params with a keyset cursor (After, Before) fail with ErrKeysetAggregate.
+// AggregateList runs the query with the total number of groups.
+func ApplyAggregate[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams) (*psql.ViewQuery[AggregateRow, []AggregateRow], error) {
+	if params == nil {
+		params = &QueryParams{}
+	}
+	if params.Keyset() {
+		return nil, ErrKeysetAggregate
+	}
+
+	agg, resolved := groupedQuery(q, params)
+	aliases := resolved.Aliases()
+	for _, key := range resolved.Keys {
+		if !slices.Contains(aliases, key.Column) {
+			continue
+		}
+		for _, def := range Postgres.OrderBy(&Sort{Keys: []SortKey{key}}) {
+			def.Expression = psql.Quote(key.Column)
+			agg.Expression.AppendOrder(def)
+		}
+	}
+	return Page(agg, &resolved.Pagination), nil
+}
+
+// groupedQuery builds the unsorted, unpaged aggregate query of params and
+// returns it with the resolved params.
+func groupedQuery[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams) (*psql.ViewQuery[AggregateRow, []AggregateRow], QueryParams) {
+	agg := &psql.ViewQuery[AggregateRow, []AggregateRow]{
+		Query: orm.Query[*dialect.SelectQuery, AggregateRow, []AggregateRow, bob.SliceTransformer[AggregateRow, []AggregateRow]]{
+			ExecQuery: q.ExecQuery.Clone(),
+			Scanner:   aggregateRows,
+		},
+	}
+
+	resolved := *params
+	if params.Schema != nil {
+		if search := params.Schema.search; search != nil && search.enabled(params.Search) {
+			agg.Apply(sm.Where(search.match(params.Search)))
+		}
+		agg = ApplyJoins(agg, params)
+		resolved = params.Schema.Resolve(*params)
+	}
+	agg = ApplyFilter(agg, &resolved.Filter)
+
+	aggregates := resolved.Aggregates
+	if len(aggregates) == 0 {
+		aggregates = []Aggregate{{Func: AggCount}}
+	}
+	columns := make([]any, 0, len(resolved.GroupBy)+len(aggregates))
+	for _, g := range resolved.GroupBy {
+		group := Postgres.group(g)
+		agg.Apply(sm.GroupBy(group))
+		columns = append(columns, group.As(g.Alias()))
+	}
+	for _, a := range aggregates {
+		columns = append(columns, Postgres.aggregate(a).As(a.Alias()))
+	}
+	agg.Apply(sm.Columns(columns...))
+	return agg, resolved
+}
+
+// AggregateList runs the aggregate query of params (see ApplyAggregate)
+// together with the number of groups, and returns the page with its total
+// and page numbers like List.
+func AggregateList[T any, S ~[]T](ctx context.Context, exec bob.Executor, q *psql.ViewQuery[T, S], params *QueryParams, opts ...ListOption) (SliceResult[AggregateRow], error) {
+	cfg := listConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if params == nil {
+		params = &QueryParams{}
+	}
+	page, err := ApplyAggregate(q, params)
+	if err != nil {
+		return SliceResult[AggregateRow]{}, err
+	}
+	grouped, _ := groupedQuery(q, params)
+
+	var (
+		rows  []AggregateRow
+		total int64
+	)
+	if cfg.windowCount {
+		// count(*) OVER () runs after GROUP BY, so it counts the groups.
+		rows, total, err = allWithTotal(ctx, exec, page)
+		if err == nil && len(rows) == 0 && params.Offset > 0 {
+			total, err = countGroups(ctx, exec, grouped)
+		}
+	} else {
+		total, err = countGroups(ctx, exec, grouped)
+		if err == nil {
+			rows, err = page.All(ctx, exec)
+		}
+	}
+	if err != nil {
+		return SliceResult[AggregateRow]{}, err
+	}
+
+	res := Slice(rows, total)
+	if cfg.url != nil {
+		return res.WithLinks(cfg.url, params), nil
+	}
+	return res.Paged(params), nil
+}
+
+// countGroups counts the rows of an aggregate query with
+// SELECT count(*) FROM (q) AS groups.
+func countGroups(ctx context.Context, exec bob.Executor, q *psql.ViewQuery[AggregateRow, []AggregateRow]) (int64, error) {
+	ctx, err := q.RunHooks(ctx, exec)
+	if err != nil {
+		return 0, err
+	}
+	count := psql.Select(sm.Columns(psql.Raw("count(*)")), sm.From(q.BaseQuery).As("groups"))
+	return bob.One(ctx, exec, count, scan.SingleColumnMapper[int64])
+}
+
+// group renders a group column, truncated with date_trunc when asked to.
+// JSON values are truncated as timestamps.
+func (d Dialect) group(g GroupKey) psql.Expression {
+	if !slices.Contains(TruncUnits, g.Trunc) {
+		return d.column(g.Column, true)
+	}
+	column := d.compared(g.Column, time.Time{})
+	return dialect.NewExpression(psql.F("date_trunc", psql.S(g.Trunc), column)())
+}
+
+// aggregate renders an aggregate call. JSON values are summed and averaged
+// as numbers.
+func (d Dialect) aggregate(a Aggregate) psql.Expression {
+	if a.Column == "" {
+		return psql.Raw("count(*)")
+	}
+	column := d.column(a.Column, true)
+	if a.Func == AggSum || a.Func == AggAvg {
+		column = d.compared(a.Column, float64(0))
+	}
+	fn := a.Func
+	if !slices.Contains([]AggFunc{AggCount, AggSum, AggAvg, AggMin, AggMax}, fn) {
+		fn = AggCount
+	}
+	return dialect.NewExpression(psql.F(string(fn), column)())
+}
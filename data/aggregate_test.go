@@ -0,0 +1,131 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stephenafamo/bob/dialect/psql"
+)
+
+func logSchema() *Schema {
+	return NewSchema(
+		Column{Name: "status", Filterable: true, Groupable: true},
+		Column{Name: "day", Source: "completed_at", Type: TypeTime, Groupable: true},
+		Column{Name: "duration", Type: TypeInt, Aggregatable: true},
+		Column{Name: "note", Aggregatable: true},
+	)
+}
+
+func applyAggregate(t *testing.T, params *QueryParams) *psql.ViewQuery[AggregateRow, []AggregateRow] {
+	t.Helper()
+	q, err := ApplyAggregate(habitsQuery(), params)
+	if err != nil {
+		t.Fatalf("apply aggregate: %v", err)
+	}
+	return q
+}
+
+func TestApplyAggregate(t *testing.T) {
+	params := &QueryParams{
+		Pagination: Pagination{Limit: int64(10)},
+		Filter:     Filter{Conditions: []FilterCondition{{Column: "status", Mode: Exact, Value: "done"}}},
+		GroupBy:    []GroupKey{{Column: "day", Trunc: "day"}, {Column: "status"}, {Column: "note"}},
+		Aggregates: []Aggregate{{Func: AggCount}, {Func: AggSum, Column: "duration"}, {Func: AggAvg, Column: "note"}},
+		Sort:       Sort{Keys: []SortKey{{Column: "day", Direction: "asc"}, {Column: "sum_duration", Direction: "desc"}, {Column: "status", Direction: "asc"}}},
+		Schema:     logSchema(),
+	}
+
+	sql, args := writeQuery(t, applyAggregate(t, params))
+
+	for _, want := range []string{
+		`date_trunc('day', "completed_at") AS "day", "status" AS "status", count(*) AS "count", sum("duration") AS "sum_duration"`,
+		`WHERE ("status" = $1)`,
+		`GROUP BY date_trunc('day', "completed_at"), "status"`,
+		`ORDER BY "day" ASC, "sum_duration" DESC, "status" ASC`,
+		`LIMIT 10`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	if strings.Contains(sql, "note") {
+		t.Fatalf("expected undeclared groups and non-numeric averages to be dropped: %s", sql)
+	}
+	if len(args) != 1 {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestApplyAggregateDefaultsToCount(t *testing.T) {
+	sql, _ := writeQuery(t, applyAggregate(t, &QueryParams{GroupBy: []GroupKey{{Column: "name"}}}))
+	if !strings.Contains(sql, `"name" AS "name", count(*) AS "count"`) || !strings.Contains(sql, `GROUP BY "name"`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestApplyAggregateRejectsKeyset(t *testing.T) {
+	params := &QueryParams{
+		Pagination: Pagination{After: &Cursor{Values: []any{"done"}}},
+		GroupBy:    []GroupKey{{Column: "status"}},
+		Sort:       Sort{Keys: []SortKey{{Column: "status", Direction: "asc"}}},
+	}
+	if _, err := ApplyAggregate(habitsQuery(), params); !errors.Is(err, ErrKeysetAggregate) {
+		t.Fatalf("expected ErrKeysetAggregate, got %v", err)
+	}
+	if _, err := AggregateList(context.Background(), &fakeExec{}, habitsQuery(), params); !errors.Is(err, ErrKeysetAggregate) {
+		t.Fatalf("expected ErrKeysetAggregate from AggregateList, got %v", err)
+	}
+}
+
+func TestAggregateListCountsGroups(t *testing.T) {
+	exec := &fakeExec{results: []fakeRows{
+		{cols: []string{"count"}, rows: [][]any{{int64(7)}}},
+		{cols: []string{"status", "count"}, rows: [][]any{{"done", int64(3)}, {"open", int64(2)}}},
+	}}
+	params := &QueryParams{
+		Pagination: Pagination{Limit: int64(2), Offset: 2},
+		Filter:     Filter{Conditions: []FilterCondition{{Column: "status", Mode: NotEqual, Value: "archived"}}},
+		GroupBy:    []GroupKey{{Column: "status"}},
+		Sort:       Sort{Keys: []SortKey{{Column: "status", Direction: "asc"}}},
+		Schema:     logSchema(),
+	}
+	u, _ := url.Parse("/api/logs/stats?group_by=status&limit=2&offset=2")
+
+	res, err := AggregateList(context.Background(), exec, habitsQuery(), params, WithURL(u))
+	if err != nil {
+		t.Fatalf("aggregate list: %v", err)
+	}
+	if len(exec.queries) != 2 {
+		t.Fatalf("expected count and page queries, got %d", len(exec.queries))
+	}
+	count := exec.queries[0]
+	if !strings.Contains(count, "FROM (SELECT") || !strings.Contains(count, `GROUP BY "status"`) || !strings.Contains(count, `) AS "groups"`) ||
+		strings.Contains(count, "ORDER BY") || strings.Contains(count, "LIMIT") {
+		t.Fatalf("unexpected count query: %s", count)
+	}
+	if len(res.Data) != 2 || res.Data[0]["status"] != "done" || res.Total != 7 || res.Page != 2 || res.PageCount != 4 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Next != "/api/logs/stats?group_by=status&limit=2&offset=4" {
+		t.Fatalf("unexpected next: %s", res.Next)
+	}
+}
+
+func TestSchemaCheckAggregate(t *testing.T) {
+	schema := logSchema()
+	if err := schema.CheckAggregate(Aggregate{Func: AggSum, Column: "note"}); err == nil {
+		t.Fatal("expected sum of a text column to be rejected")
+	}
+	if err := schema.CheckAggregate(Aggregate{Func: AggMax, Column: "note"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.CheckGroup(GroupKey{Column: "status", Trunc: "day"}); err == nil {
+		t.Fatal("expected truncating a text column to be rejected")
+	}
+	if err := schema.CheckGroup(GroupKey{Column: "duration"}); err == nil {
+		t.Fatal("expected a column that is not groupable to be rejected")
+	}
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor holds the sort key values of the row a keyset page starts after
+// (or ends before). Values are in the same order as the sort keys.
+type Cursor struct {
+	Values []any
+}
+
+// Encode returns the opaque token clients pass back as after= or before=.
+// Values must be JSON encodable.
+func (c Cursor) Encode() (string, error) {
+	raw, err := json.Marshal(c.Values)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode. Numbers are decoded
+// as their string form so they can be coerced with the column type.
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var values []any
+	if err := dec.Decode(&values); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: no values", ErrInvalidCursor)
+	}
+	for i, v := range values {
+		if n, ok := v.(json.Number); ok {
+			values[i] = n.String()
+		}
+	}
+	return &Cursor{Values: values}, nil
+}
+
+// CoerceCursor parses the cursor values with the column types of the sort
+// keys they belong to. Date columns also accept the RFC 3339 timestamps a
+// time.Time is JSON encoded as, which is how CursorSlice writes them.
+// Sort keys on Nullable columns are rejected.
+func (s *Schema) CoerceCursor(keys []SortKey, cursor *Cursor) (*Cursor, error) {
+	if len(keys) != len(cursor.Values) {
+		return nil, fmt.Errorf("%w: expected %d values, got %d", ErrInvalidCursor, len(keys), len(cursor.Values))
+	}
+	values := make([]any, 0, len(cursor.Values))
+	for i, v := range cursor.Values {
+		raw, ok := v.(string)
+		column, declared := s.Lookup(keys[i].Column)
+		if declared && column.Nullable {
+			return nil, fmt.Errorf("%w: column %q is nullable and cannot be paged by cursor", ErrInvalidCursor, keys[i].Column)
+		}
+		if !ok || !declared {
+			values = append(values, v)
+			continue
+		}
+		typed, err := column.ParseValue(raw)
+		if err != nil && column.Type == TypeDate {
+			if at, terr := time.Parse(time.RFC3339, raw); terr == nil {
+				typed, err = at, nil
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+		}
+		values = append(values, typed)
+	}
+	return &Cursor{Values: values}, nil
+}
+
+// PageByCursor applies keyset pagination: a WHERE comparison against the
+// cursor values, the sort keys as ORDER BY, and a LIMIT one row larger than
+// requested so CursorSlice can tell whether another page exists.
+//
+// When paging backwards (Before) the ordering is reversed; CursorSlice puts
+// the rows back in the requested order. The sort keys should end with a
+// unique column and must not contain NULLs; declare nullable columns with
+// Column.Nullable so CoerceCursor rejects them.
+func PageByCursor[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams) *psql.ViewQuery[T, S] {
+	if where := Postgres.KeysetWhere(params); where != nil {
+		q.Apply(sm.Where(where))
+	}
+	sort := params.KeysetSort()
+	q = Order(q, &sort)
+	if limit, ok := params.PageSize(); ok {
+		q.Apply(sm.Limit(limit + 1))
+	}
+	return q
+}
+
+// KeysetSort returns the ordering for a keyset page: the sort keys, reversed
+// when paging backwards.
+func (p *QueryParams) KeysetSort() Sort {
+	if p.Before != nil {
+		return Sort{Keys: reverseKeys(p.Keys)}
+	}
+	return p.Sort
+}
+
+// CursorSlice trims the extra row fetched by PageByCursor and fills in the
+// next and previous cursors. key returns the sort key values of a row in the
+// same order as params.Keys; an error is returned if they cannot be encoded.
+func CursorSlice[T any](data []T, count int64, params *QueryParams, key func(T) []any) (SliceResult[T], error) {
+	backwards := params.Before != nil
+	limit, limited := params.PageSize()
+
+	more := limited && int64(len(data)) > limit
+	if more {
+		data = data[:limit]
+	}
+	if backwards {
+		data = slices.Clone(data)
+		slices.Reverse(data)
+	}
+
+	result := Slice(data, count)
+	if len(data) == 0 {
+		return result, nil
+	}
+
+	first, err := Cursor{Values: key(data[0])}.Encode()
+	if err != nil {
+		return result, err
+	}
+	last, err := Cursor{Values: key(data[len(data)-1])}.Encode()
+	if err != nil {
+		return result, err
+	}
+	if backwards {
+		result.NextCursor = last
+		if more {
+			result.PrevCursor = first
+		}
+		return result, nil
+	}
+	if more {
+		result.NextCursor = last
+	}
+	if params.After != nil {
+		result.PrevCursor = first
+	}
+	return result, nil
+}
+
+// keyset builds the comparison selecting rows after values in the order
+// given by keys. Keys sharing one direction use a row comparison; mixed
+// directions expand into (a > x) OR (a = x AND b < y) ...
+func (d Dialect) keyset(keys []SortKey, values []any) psql.Expression {
+	if len(keys) == 0 || len(keys) != len(values) {
+		return psql.Raw("FALSE")
+	}
+
+	uniform := true
+	for _, key := range keys[1:] {
+		if key.Direction != keys[0].Direction {
+			uniform = false
+			break
+		}
+	}
+
+	if uniform {
+		columns := make([]bob.Expression, 0, len(keys))
+		for _, key := range keys {
+			columns = append(columns, d.compared(key.Column, values[len(columns)]))
+		}
+		if keys[0].Direction == "desc" {
+			return psql.Group(columns...).LT(psql.ArgGroup(values...))
+		}
+		return psql.Group(columns...).GT(psql.ArgGroup(values...))
+	}
+
+	ors := make([]bob.Expression, 0, len(keys))
+	for i, key := range keys {
+		ands := make([]bob.Expression, 0, i+1)
+		for j := range i {
+			ands = append(ands, d.compared(keys[j].Column, values[j]).EQ(psql.Arg(values[j])))
+		}
+		column := d.compared(key.Column, values[i])
+		if key.Direction == "desc" {
+			ands = append(ands, column.LT(psql.Arg(values[i])))
+		} else {
+			ands = append(ands, column.GT(psql.Arg(values[i])))
+		}
+		ors = append(ors, psql.And(ands...))
+	}
+	return psql.Or(ors...)
+}
+
+func reverseKeys(keys []SortKey) []SortKey {
+	out := make([]SortKey, 0, len(keys))
+	for _, key := range keys {
+		switch key.Direction {
+		case "desc":
+			key.Direction = "asc"
+		default:
+			key.Direction = "desc"
+		}
+		switch key.Nulls {
+		case "first":
+			key.Nulls = "last"
+		case "last":
+			key.Nulls = "first"
+		}
+		out = append(out, key)
+	}
+	return out
+}
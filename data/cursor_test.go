@@ -0,0 +1,186 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	token := encodeCursor(t, "2024-01-01T00:00:00Z", 42)
+
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(cursor.Values, []any{"2024-01-01T00:00:00Z", "42"}) {
+		t.Fatalf("unexpected values: %#v", cursor.Values)
+	}
+
+	if _, err := DecodeCursor("not a cursor"); err == nil {
+		t.Fatal("expected error for malformed cursor")
+	}
+}
+
+func TestPageByCursorUniformDirection(t *testing.T) {
+	params := &QueryParams{
+		Pagination: Pagination{Limit: int64(20), After: &Cursor{Values: []any{"2024-01-01", "id-9"}}},
+		Sort: Sort{Keys: []SortKey{
+			{Column: "completed_at", Direction: "desc"},
+			{Column: "id", Direction: "desc"},
+		}},
+	}
+
+	sql, args := writeQuery(t, PageByCursor(habitsQuery(), params))
+	if !strings.Contains(sql, `WHERE (("completed_at", "id") < ($1, $2))`) {
+		t.Fatalf("expected row comparison, got: %s", sql)
+	}
+	if !strings.Contains(sql, `ORDER BY "completed_at" DESC, "id" DESC`) || !strings.Contains(sql, "LIMIT 21") {
+		t.Fatalf("unexpected ordering or limit: %s", sql)
+	}
+	if len(args) != 2 {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestPageByCursorBeforeMixedDirections(t *testing.T) {
+	params := &QueryParams{
+		Pagination: Pagination{Limit: int64(10), Before: &Cursor{Values: []any{"gym", "id-3"}}},
+		Sort: Sort{Keys: []SortKey{
+			{Column: "name", Direction: "asc"},
+			{Column: "id", Direction: "desc"},
+		}},
+	}
+
+	sql, _ := writeQuery(t, PageByCursor(habitsQuery(), params))
+	if !strings.Contains(sql, `(("name" < $1)) OR (("name" = $2) AND ("id" > $3))`) {
+		t.Fatalf("expected expanded comparison, got: %s", sql)
+	}
+	if !strings.Contains(sql, `ORDER BY "name" DESC, "id" ASC`) {
+		t.Fatalf("expected reversed ordering, got: %s", sql)
+	}
+}
+
+func TestCursorSlice(t *testing.T) {
+	rows := []habit{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	key := func(h habit) []any { return []any{h.ID} }
+
+	params := &QueryParams{
+		Pagination: Pagination{Limit: int64(2), After: &Cursor{Values: []any{"0"}}},
+		Sort:       Sort{Keys: []SortKey{{Column: "id", Direction: "asc"}}},
+	}
+	result, err := CursorSlice(rows, 10, params, key)
+	if err != nil {
+		t.Fatalf("cursor slice: %v", err)
+	}
+	if len(result.Data) != 2 || result.Data[1].ID != "2" {
+		t.Fatalf("expected extra row to be trimmed, got %+v", result.Data)
+	}
+	if result.NextCursor != encodeCursor(t, "2") {
+		t.Fatalf("unexpected next cursor %q", result.NextCursor)
+	}
+	if result.PrevCursor != encodeCursor(t, "1") {
+		t.Fatalf("unexpected prev cursor %q", result.PrevCursor)
+	}
+
+	params.After, params.Before = nil, &Cursor{Values: []any{"4"}}
+	result, _ = CursorSlice(rows, 10, params, key)
+	if len(result.Data) != 2 || result.Data[0].ID != "2" || result.Data[1].ID != "1" {
+		t.Fatalf("expected backwards page to be reversed, got %+v", result.Data)
+	}
+	if result.PrevCursor == "" || result.NextCursor == "" {
+		t.Fatalf("expected both cursors, got %+v", result)
+	}
+}
+
+func TestCursorEncodeError(t *testing.T) {
+	if _, err := (Cursor{Values: []any{func() {}}}).Encode(); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	rows := []habit{{ID: "1"}}
+	params := &QueryParams{Pagination: Pagination{Limit: int64(1)}}
+	if _, err := CursorSlice(rows, 1, params, func(habit) []any { return []any{make(chan int)} }); err == nil {
+		t.Fatal("expected CursorSlice to report the encoding error")
+	}
+}
+
+func TestCoerceCursorRejectsNullable(t *testing.T) {
+	schema := NewSchema(
+		Column{Name: "completed_at", Type: TypeTime, Sortable: true, Nullable: true},
+		Column{Name: "id", Sortable: true},
+	)
+	keys := []SortKey{{Column: "completed_at", Direction: "desc"}, {Column: "id", Direction: "desc"}}
+	_, err := schema.CoerceCursor(keys, &Cursor{Values: []any{"2024-05-01T00:00:00Z", "7"}})
+	if !errors.Is(err, ErrInvalidCursor) || !strings.Contains(err.Error(), "nullable") {
+		t.Fatalf("expected nullable sort key to be rejected, got %v", err)
+	}
+}
+
+func encodeCursor(t *testing.T, values ...any) string {
+	t.Helper()
+	token, err := Cursor{Values: values}.Encode()
+	if err != nil {
+		t.Fatalf("encode cursor: %v", err)
+	}
+	return token
+}
+
+func TestCursorRoundTripColumnTypes(t *testing.T) {
+	id := uuid.Must(uuid.FromString("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+	columns := []Column{
+		{Name: "text", Type: TypeText},
+		{Name: "int", Type: TypeInt},
+		{Name: "float", Type: TypeFloat},
+		{Name: "bool", Type: TypeBool},
+		{Name: "uuid", Type: TypeUUID},
+		{Name: "time", Type: TypeTime},
+		{Name: "date", Type: TypeDate},
+		{Name: "enum", Type: TypeEnum, Enum: []string{"active", "paused"}},
+	}
+	row := []any{
+		"gym",
+		int64(42),
+		1.5,
+		true,
+		id,
+		time.Date(2024, 5, 1, 8, 30, 15, 500, time.UTC),
+		time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		"paused",
+	}
+
+	schema := NewSchema(columns...)
+	keys := make([]SortKey, 0, len(columns))
+	for _, c := range columns {
+		keys = append(keys, SortKey{Column: c.Name, Direction: "asc"})
+	}
+	params := &QueryParams{Pagination: Pagination{Limit: int64(1)}, Sort: Sort{Keys: keys}}
+	result, err := CursorSlice([][]any{row, row}, 2, params, func(r []any) []any { return r })
+	if err != nil {
+		t.Fatalf("cursor slice: %v", err)
+	}
+
+	decoded, err := DecodeCursor(result.NextCursor)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	cursor, err := schema.CoerceCursor(keys, decoded)
+	if err != nil {
+		t.Fatalf("coerce: %v", err)
+	}
+	for i, want := range row {
+		got := cursor.Values[i]
+		if at, ok := want.(time.Time); ok {
+			if gotAt, ok := got.(time.Time); !ok || !gotAt.Equal(at) {
+				t.Errorf("%s: expected %v, got %#v", columns[i].Name, want, got)
+			}
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: expected %#v, got %#v", columns[i].Name, want, got)
+		}
+	}
+}
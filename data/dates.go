@@ -0,0 +1,178 @@
+package data
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// shortOffset matches offsets such as -7d, +2h or -3mo.
+	shortOffset = regexp.MustCompile(`^([+-])(\d+)(s|m|h|d|w|mo|y)$`)
+	// isoDuration matches signed ISO 8601 durations such as P7D, -P1M or PT12H.
+	isoDuration = regexp.MustCompile(`^([+-]?)P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+)
+
+// offset is a calendar offset. Years, months and days follow the calendar
+// and DST of the location; a month from March 31 is April 30, not May 1.
+type offset struct {
+	years, months, days int
+	clock               time.Duration
+}
+
+func (o offset) from(t time.Time) time.Time {
+	if o.years != 0 || o.months != 0 {
+		first := time.Date(t.Year()+o.years, t.Month()+time.Month(o.months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		last := first.AddDate(0, 1, -1).Day()
+		t = first.AddDate(0, 0, min(t.Day(), last)-1)
+	}
+	return t.AddDate(0, 0, o.days).Add(o.clock)
+}
+
+// parseOffset parses -7d style offsets and ISO 8601 durations. ISO durations
+// without a sign point into the past, so after=P7D means the last 7 days.
+func parseOffset(raw string) (offset, bool) {
+	if m := shortOffset.FindStringSubmatch(strings.ToLower(raw)); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return offset{}, false
+		}
+		if m[1] == "-" {
+			n = -n
+		}
+		var o offset
+		switch m[3] {
+		case "s":
+			o.clock = time.Duration(n) * time.Second
+		case "m":
+			o.clock = time.Duration(n) * time.Minute
+		case "h":
+			o.clock = time.Duration(n) * time.Hour
+		case "d":
+			o.days = n
+		case "w":
+			o.days = 7 * n
+		case "mo":
+			o.months = n
+		case "y":
+			o.years = n
+		}
+		return o, true
+	}
+
+	m := isoDuration.FindStringSubmatch(strings.ToUpper(raw))
+	if m == nil {
+		return offset{}, false
+	}
+	n := make([]int, len(m))
+	empty := true
+	for i := 2; i < len(m); i++ {
+		if m[i] == "" {
+			continue
+		}
+		v, err := strconv.Atoi(m[i])
+		if err != nil {
+			return offset{}, false
+		}
+		n[i], empty = v, false
+	}
+	if empty {
+		return offset{}, false
+	}
+	sign := -1
+	if m[1] == "+" {
+		sign = 1
+	}
+	return offset{
+		years:  sign * n[2],
+		months: sign * n[3],
+		days:   sign * (7*n[4] + n[5]),
+		clock:  time.Duration(sign) * (time.Duration(n[6])*time.Hour + time.Duration(n[7])*time.Minute + time.Duration(n[8])*time.Second),
+	}, true
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// ResolveTime resolves a relative date value against now: "now", "today",
+// "yesterday", "tomorrow", offsets such as -7d, +2h or -3mo, and ISO 8601
+// durations such as P7D (in the past) or +PT12H. Days, weeks, months and
+// years follow the calendar in now's location. ok is false for anything
+// else, including absolute timestamps.
+func ResolveTime(raw string, now time.Time) (t time.Time, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "now":
+		return now, true
+	case "today":
+		return startOfDay(now), true
+	case "yesterday":
+		return startOfDay(now).AddDate(0, 0, -1), true
+	case "tomorrow":
+		return startOfDay(now).AddDate(0, 0, 1), true
+	}
+	o, ok := parseOffset(strings.TrimSpace(raw))
+	if !ok {
+		return time.Time{}, false
+	}
+	return o.from(now), true
+}
+
+// ResolvePeriod resolves a calendar period to its half-open bounds
+// [start, end) in now's location. Periods are today, yesterday, tomorrow,
+// this_/last_/next_ followed by week (starting Monday), month, quarter or
+// year, and relative offsets, where -7d means from 7 days ago until now.
+func ResolvePeriod(raw string, now time.Time) (start, end time.Time, ok bool) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	day := startOfDay(now)
+	switch raw {
+	case "today":
+		return day, day.AddDate(0, 0, 1), true
+	case "yesterday":
+		return day.AddDate(0, 0, -1), day, true
+	case "tomorrow":
+		return day.AddDate(0, 0, 1), day.AddDate(0, 0, 2), true
+	}
+
+	if which, unit, found := strings.Cut(raw, "_"); found {
+		var shift int
+		switch which {
+		case "this":
+		case "last":
+			shift = -1
+		case "next":
+			shift = 1
+		default:
+			return time.Time{}, time.Time{}, false
+		}
+
+		switch unit {
+		case "week":
+			monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+			start = monday.AddDate(0, 0, 7*shift)
+			return start, start.AddDate(0, 0, 7), true
+		case "month":
+			start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location()).AddDate(0, shift, 0)
+			return start, start.AddDate(0, 1, 0), true
+		case "quarter":
+			month := time.Month((int(day.Month())-1)/3*3 + 1)
+			start = time.Date(day.Year(), month, 1, 0, 0, 0, 0, day.Location()).AddDate(0, 3*shift, 0)
+			return start, start.AddDate(0, 3, 0), true
+		case "year":
+			start = time.Date(day.Year()+shift, time.January, 1, 0, 0, 0, 0, day.Location())
+			return start, start.AddDate(1, 0, 0), true
+		}
+		return time.Time{}, time.Time{}, false
+	}
+
+	o, ok := parseOffset(raw)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	start, end = now, o.from(now)
+	if end.Before(start) {
+		start, end = end, start
+	}
+	return start, end, true
+}
@@ -0,0 +1,89 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveTime(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	// The day after the switch to summer time.
+	now := time.Date(2024, time.March, 31, 15, 30, 0, 0, warsaw)
+
+	tests := []struct {
+		raw  string
+		want time.Time
+	}{
+		{"now", now},
+		{"today", time.Date(2024, time.March, 31, 0, 0, 0, 0, warsaw)},
+		{"yesterday", time.Date(2024, time.March, 30, 0, 0, 0, 0, warsaw)},
+		{"-1d", time.Date(2024, time.March, 30, 15, 30, 0, 0, warsaw)},
+		{"-2h", now.Add(-2 * time.Hour)},
+		{"-1mo", time.Date(2024, time.February, 29, 15, 30, 0, 0, warsaw)},
+		{"+1w", time.Date(2024, time.April, 7, 15, 30, 0, 0, warsaw)},
+		{"P7D", time.Date(2024, time.March, 24, 15, 30, 0, 0, warsaw)},
+		{"-P1Y2M", time.Date(2023, time.January, 31, 15, 30, 0, 0, warsaw)},
+		{"+PT1H30M", now.Add(90 * time.Minute)},
+	}
+	for _, tt := range tests {
+		got, ok := ResolveTime(tt.raw, now)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("ResolveTime(%q) = %v, %v; want %v", tt.raw, got, ok, tt.want)
+		}
+	}
+
+	for _, raw := range []string{"", "7d", "P", "PT", "2024-01-01", "-7x"} {
+		if _, ok := ResolveTime(raw, now); ok {
+			t.Errorf("expected %q not to resolve", raw)
+		}
+	}
+}
+
+func TestResolvePeriod(t *testing.T) {
+	now := time.Date(2024, time.May, 15, 10, 0, 0, 0, time.UTC) // a Wednesday
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		raw        string
+		start, end time.Time
+	}{
+		{"today", day(time.May, 15), day(time.May, 16)},
+		{"this_week", day(time.May, 13), day(time.May, 20)},
+		{"last_week", day(time.May, 6), day(time.May, 13)},
+		{"this_month", day(time.May, 1), day(time.June, 1)},
+		{"last_month", day(time.April, 1), day(time.May, 1)},
+		{"this_quarter", day(time.April, 1), day(time.July, 1)},
+		{"next_year", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"-7d", time.Date(2024, time.May, 8, 10, 0, 0, 0, time.UTC), now},
+		{"+P1D", now, time.Date(2024, time.May, 16, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		start, end, ok := ResolvePeriod(tt.raw, now)
+		if !ok || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("ResolvePeriod(%q) = [%v, %v), %v; want [%v, %v)", tt.raw, start, end, ok, tt.start, tt.end)
+		}
+	}
+
+	if _, _, ok := ResolvePeriod("this_fortnight", now); ok {
+		t.Fatal("expected unknown period not to resolve")
+	}
+}
+
+func TestApplyFilterWithin(t *testing.T) {
+	start := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	f := &Filter{Conditions: []FilterCondition{
+		{Column: "completed_at", Mode: Within, Args: []any{start, start.AddDate(0, 1, 0)}},
+	}}
+
+	sql, args := writeQuery(t, ApplyFilter(habitsQuery(), f))
+	if !strings.Contains(sql, `("completed_at" >= $1) AND ("completed_at" < $2)`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+	if len(args) != 2 || args[0] != start {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
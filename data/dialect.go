@@ -0,0 +1,125 @@
+package data
+
+import (
+	"math"
+	"strings"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/clause"
+)
+
+// Dialect captures the SQL differences between the databases the query
+// helpers support. The expressions it builds only carry structure; quoting
+// and placeholders come from the bob dialect the query is written with, so
+// they can be used with psql, mysql and sqlite queries alike.
+type Dialect struct {
+	name string
+
+	// like and ilike are the case-sensitive and case-insensitive pattern
+	// operators; escape is the SQL literal of the backslash they escape
+	// wildcards with. glob marks like as GLOB, which takes glob patterns.
+	like, ilike, escape string
+	glob                bool
+	// binary compares text as BINARY strings in =, <>, IN and NOT IN, so
+	// they match case like in the other dialects.
+	binary bool
+	// nullsOrder reports native NULLS FIRST/LAST support.
+	nullsOrder bool
+	// unlimited is the LIMIT value meaning "no limit" when an offset is
+	// set, nil when the dialect has no such value.
+	unlimited any
+
+	// jsonChain extracts JSON keys one -> at a time instead of with a
+	// '$.a.b' path; jsonCasts casts extracted values to the argument type;
+	// jsonOrder orders JSON values rather than extracted text.
+	jsonChain, jsonCasts, jsonOrder bool
+}
+
+var (
+	Postgres = Dialect{name: "postgres", like: "LIKE", ilike: "ILIKE", escape: `'\'`, nullsOrder: true, unlimited: "ALL", jsonChain: true, jsonCasts: true, jsonOrder: true}
+	// MySQL matches case-insensitive patterns with LIKE, which ignores case
+	// under the default collations, and case-sensitive ones with LIKE
+	// BINARY. Exact text comparisons are made BINARY for the same reason.
+	// It emulates NULLS FIRST/LAST.
+	MySQL = Dialect{name: "mysql", like: "LIKE BINARY", ilike: "LIKE", escape: `'\\'`, binary: true, unlimited: int64(math.MaxInt64), jsonOrder: true}
+	// SQLite matches case-insensitive patterns with LIKE, which ignores case
+	// for ASCII, and case-sensitive ones with GLOB.
+	SQLite = Dialect{name: "sqlite", like: "GLOB", ilike: "LIKE", escape: `'\'`, glob: true, nullsOrder: true, unlimited: int64(-1)}
+)
+
+func (d Dialect) String() string {
+	return d.name
+}
+
+// Where returns one where expression per filter condition and expression.
+// Apply each with the dialect's sm.Where.
+func (d Dialect) Where(f *Filter) []bob.Expression {
+	exprs := make([]bob.Expression, 0, len(f.Conditions)+len(f.Exprs))
+	for _, condition := range f.Conditions {
+		exprs = append(exprs, d.condition(condition))
+	}
+	for _, e := range f.Exprs {
+		exprs = append(exprs, d.filterExpr(e))
+	}
+	return exprs
+}
+
+// OrderBy returns the ORDER BY terms for s. Keys with an unknown direction
+// are skipped. Dialects without NULLS FIRST/LAST get a leading
+// "column IS NULL" term per key instead.
+func (d Dialect) OrderBy(s *Sort) []clause.OrderDef {
+	defs := make([]clause.OrderDef, 0, len(s.Keys))
+	for _, key := range s.Keys {
+		var direction string
+		switch key.Direction {
+		case "asc":
+			direction = "ASC"
+		case "desc":
+			direction = "DESC"
+		default:
+			continue
+		}
+
+		column := d.ordered(key.Column)
+		def := clause.OrderDef{Expression: column, Direction: direction}
+		switch key.Nulls {
+		case "first", "last":
+			if d.nullsOrder {
+				def.Nulls = strings.ToUpper(key.Nulls)
+				break
+			}
+			nulls := clause.OrderDef{Expression: column.IsNull(), Direction: "ASC"}
+			if key.Nulls == "first" {
+				nulls.Direction = "DESC"
+			}
+			defs = append(defs, nulls)
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// PageLimit returns the LIMIT to apply for pg, or false when the query
+// should have no LIMIT clause. "ALL" only needs a LIMIT to go with an offset.
+func (d Dialect) PageLimit(pg *Pagination) (any, bool) {
+	if limit, ok := pg.PageSize(); ok {
+		return limit, true
+	}
+	if d.unlimited == nil || pg.Offset == 0 {
+		return nil, false
+	}
+	return d.unlimited, true
+}
+
+// KeysetWhere returns the comparison selecting the rows after params.After
+// (or before params.Before), or nil when there is no cursor.
+func (d Dialect) KeysetWhere(params *QueryParams) bob.Expression {
+	switch {
+	case params.After != nil:
+		return d.keyset(params.Keys, params.After.Values)
+	case params.Before != nil:
+		return d.keyset(reverseKeys(params.Keys), params.Before.Values)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,269 @@
+package data
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// modeTokens are the filter suffixes Encode writes for each match mode.
+var modeTokens = map[MatchMode]string{
+	Exact:           "eq",
+	CaseInsensitive: "ci",
+	Anywhere:        "contains",
+	Start:           "starts_with",
+	End:             "ends_with",
+	NotEqual:        "ne",
+	GreaterThan:     "gt",
+	GreaterOrEqual:  "gte",
+	LessThan:        "lt",
+	LessOrEqual:     "lte",
+	In:              "in",
+	NotIn:           "not_in",
+	Between:         "between",
+	IsNull:          "is_null",
+	NotNull:         "not_null",
+	Within:          "within",
+}
+
+// Encode returns the query string params that parse back into p with the
+// default syntax: filter, sort, group_by, agg, q, fields, limit, offset,
+// after and before. A limit of "ALL" is written out, since a missing limit
+// means the server's default limit; a nil Limit is left out. The error
+// reports cursor values that cannot be encoded.
+// Expressions built by Schema.Resolve (ExistsExpr) cannot be encoded and
+// are left out. Cursor values only keep their types when parsed against a
+// schema, and plain filter values lose leading and trailing spaces.
+func (p QueryParams) Encode() (url.Values, error) {
+	values := url.Values{}
+	for _, cond := range p.Conditions {
+		values.Add("filter", encodeCondition(cond, false))
+	}
+	for _, e := range p.Exprs {
+		if raw, ok := encodeExpr(e); ok {
+			values.Add("filter", raw)
+		}
+	}
+
+	keys := make([]string, 0, len(p.Keys))
+	for _, key := range p.Keys {
+		raw := key.Column
+		if key.Direction == "desc" {
+			raw = "-" + raw
+		}
+		if key.Nulls != "" {
+			raw += ":nulls_" + key.Nulls
+		}
+		keys = append(keys, raw)
+	}
+	if len(keys) > 0 {
+		values.Set("sort", strings.Join(keys, ","))
+	}
+
+	groups := make([]string, 0, len(p.GroupBy))
+	for _, g := range p.GroupBy {
+		raw := g.Column
+		if g.Trunc != "" {
+			raw += ":" + g.Trunc
+		}
+		groups = append(groups, raw)
+	}
+	if len(groups) > 0 {
+		values.Set("group_by", strings.Join(groups, ","))
+	}
+	aggs := make([]string, 0, len(p.Aggregates))
+	for _, a := range p.Aggregates {
+		raw := string(a.Func)
+		if a.Column != "" {
+			raw += ":" + a.Column
+		}
+		aggs = append(aggs, raw)
+	}
+	if len(aggs) > 0 {
+		values.Set("agg", strings.Join(aggs, ","))
+	}
+
+	if p.Search != "" {
+		values.Set("q", p.Search)
+	}
+	if len(p.Fields) > 0 {
+		values.Set("fields", strings.Join(p.Fields, ","))
+	}
+	if limit, ok := p.PageSize(); ok {
+		values.Set("limit", strconv.FormatInt(limit, 10))
+	} else if p.Limit != nil {
+		values.Set("limit", "ALL")
+	}
+	if p.Offset > 0 {
+		values.Set("offset", strconv.FormatInt(p.Offset, 10))
+	}
+	for name, cursor := range map[string]*Cursor{"after": p.After, "before": p.Before} {
+		if cursor == nil {
+			continue
+		}
+		token, err := cursor.Encode()
+		if err != nil {
+			return nil, err
+		}
+		values.Set(name, token)
+	}
+	return values, nil
+}
+
+// encodeCondition writes column_mode=value. Inside expressions values that
+// would break the grammar are quoted.
+func encodeCondition(cond FilterCondition, quote bool) string {
+	token := modeTokens[cond.Mode]
+	if cond.IgnoreCase && cond.Mode != CaseInsensitive {
+		token = "i" + token
+	}
+	raw := cond.Column + "_" + token + "="
+	if cond.Mode == IsNull || cond.Mode == NotNull {
+		return raw
+	}
+	if quote && strings.ContainsAny(cond.Value, `,()"\ `) {
+		return raw + `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(cond.Value) + `"`
+	}
+	return raw + cond.Value
+}
+
+func encodeExpr(e FilterExpr) (string, bool) {
+	var (
+		op       string
+		operands []FilterExpr
+	)
+	switch e := e.(type) {
+	case FilterCondition:
+		return encodeCondition(e, true), true
+	case AndExpr:
+		op, operands = "and", e
+	case OrExpr:
+		op, operands = "or", e
+	case NotExpr:
+		op, operands = "not", []FilterExpr{e.Expr}
+	default:
+		return "", false
+	}
+
+	parts := make([]string, 0, len(operands))
+	for _, operand := range operands {
+		raw, ok := encodeExpr(operand)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, raw)
+	}
+	return op + "(" + strings.Join(parts, ",") + ")", true
+}
+
+// QueryBuilder builds QueryParams fluently, for API clients and tests:
+//
+//	values, err := data.NewQuery().Where("name", data.Anywhere, "gym").OrderDesc("created_at").Limit(20).Encode()
+type QueryBuilder struct {
+	params QueryParams
+}
+
+func NewQuery() *QueryBuilder {
+	return &QueryBuilder{}
+}
+
+// Where adds a condition. List modes take several values, IsNull and
+// NotNull none. Values are written as the column type of the builder's
+// schema expects them (see Schema), times as RFC 3339 otherwise.
+func (b *QueryBuilder) Where(column string, mode MatchMode, values ...any) *QueryBuilder {
+	c, _ := b.params.Schema.Lookup(column)
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, c.FormatValue(v))
+	}
+	b.params.Conditions = append(b.params.Conditions, FilterCondition{
+		Column: column,
+		Mode:   mode,
+		Value:  strings.Join(parts, ","),
+	})
+	return b
+}
+
+// Schema sets the schema values passed to Where are formatted with, so a
+// time.Time on a TypeDate column is written as YYYY-MM-DD. Set it before
+// the conditions.
+func (b *QueryBuilder) Schema(s *Schema) *QueryBuilder {
+	b.params.Schema = s
+	return b
+}
+
+// WhereExpr adds a filter expression, e.g. an OrExpr of conditions.
+func (b *QueryBuilder) WhereExpr(e FilterExpr) *QueryBuilder {
+	b.params.Exprs = append(b.params.Exprs, e)
+	return b
+}
+
+func (b *QueryBuilder) OrderAsc(column string) *QueryBuilder {
+	b.params.Keys = append(b.params.Keys, SortKey{Column: column, Direction: "asc"})
+	return b
+}
+
+func (b *QueryBuilder) OrderDesc(column string) *QueryBuilder {
+	b.params.Keys = append(b.params.Keys, SortKey{Column: column, Direction: "desc"})
+	return b
+}
+
+func (b *QueryBuilder) GroupBy(columns ...string) *QueryBuilder {
+	for _, column := range columns {
+		b.params.GroupBy = append(b.params.GroupBy, GroupKey{Column: column})
+	}
+	return b
+}
+
+// GroupByTrunc groups by a time column truncated to unit, see TruncUnits.
+func (b *QueryBuilder) GroupByTrunc(column, unit string) *QueryBuilder {
+	b.params.GroupBy = append(b.params.GroupBy, GroupKey{Column: column, Trunc: unit})
+	return b
+}
+
+// Aggregate adds an aggregate; column is empty for count(*).
+func (b *QueryBuilder) Aggregate(fn AggFunc, column string) *QueryBuilder {
+	b.params.Aggregates = append(b.params.Aggregates, Aggregate{Func: fn, Column: column})
+	return b
+}
+
+func (b *QueryBuilder) Limit(limit int64) *QueryBuilder {
+	b.params.Limit = limit
+	return b
+}
+
+func (b *QueryBuilder) Offset(offset int64) *QueryBuilder {
+	b.params.Offset = offset
+	return b
+}
+
+// After and Before page by cursor, see PageByCursor.
+func (b *QueryBuilder) After(c Cursor) *QueryBuilder {
+	b.params.After, b.params.Before = &c, nil
+	return b
+}
+
+func (b *QueryBuilder) Before(c Cursor) *QueryBuilder {
+	b.params.After, b.params.Before = nil, &c
+	return b
+}
+
+func (b *QueryBuilder) Fields(fields ...string) *QueryBuilder {
+	b.params.Fields = append(b.params.Fields, fields...)
+	return b
+}
+
+func (b *QueryBuilder) Search(text string) *QueryBuilder {
+	b.params.Search = text
+	return b
+}
+
+// Params returns a copy of the built params.
+func (b *QueryBuilder) Params() *QueryParams {
+	params := b.params
+	return &params
+}
+
+func (b *QueryBuilder) Encode() (url.Values, error) {
+	return b.params.Encode()
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestQueryBuilderEncode(t *testing.T) {
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	got, err := NewQuery().
+		Where("name", Anywhere, "gym").
+		Where("status", In, "active", "paused").
+		Where("created_at", GreaterOrEqual, since).
+		Where("deleted_at", IsNull).
+		WhereExpr(OrExpr{
+			FilterCondition{Column: "name", Mode: Exact, Value: "a, (b)"},
+			NotExpr{Expr: FilterCondition{Column: "score", Mode: LessThan, Value: "3"}},
+		}).
+		OrderDesc("created_at").
+		OrderAsc("name").
+		Fields("id", "name").
+		Limit(20).
+		Offset(40).
+		Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	want := url.Values{
+		"filter": {
+			"name_contains=gym",
+			"status_in=active,paused",
+			"created_at_gte=2024-05-01T00:00:00Z",
+			"deleted_at_is_null=",
+			`or(name_eq="a, (b)",not(score_lt=3))`,
+		},
+		"sort":   {"-created_at,name"},
+		"fields": {"id,name"},
+		"limit":  {"20"},
+		"offset": {"40"},
+	}
+	if got.Encode() != want.Encode() {
+		t.Fatalf("unexpected values\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestQueryParamsEncodeOmitsDefaults(t *testing.T) {
+	params := QueryParams{
+		Pagination: Pagination{Limit: "ALL", After: &Cursor{Values: []any{"x"}}},
+		Sort:       Sort{Keys: []SortKey{{Column: "id", Direction: "asc", Nulls: "last"}}},
+	}
+	got, err := params.Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if got.Get("limit") != "ALL" || got.Has("offset") {
+		t.Fatalf("expected limit=ALL and no offset, got %v", got)
+	}
+	if got.Get("sort") != "id:nulls_last" || got.Get("after") != encodeCursor(t, "x") {
+		t.Fatalf("unexpected values %v", got)
+	}
+
+	params.Limit = nil
+	if got, _ := params.Encode(); got.Has("limit") {
+		t.Fatalf("expected no limit for a nil Limit, got %v", got)
+	}
+}
+
+func TestQueryBuilderFormatsWithSchema(t *testing.T) {
+	schema := NewSchema(
+		Column{Name: "due", Type: TypeDate, Filterable: true},
+		Column{Name: "done_at", Type: TypeTime, Filterable: true},
+	)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	params := NewQuery().Schema(schema).
+		Where("due", Between, day, day.AddDate(0, 0, 7)).
+		Where("done_at", GreaterThan, day).
+		Params()
+
+	want := []string{"2024-05-01,2024-05-08", "2024-05-01T00:00:00Z"}
+	for i, cond := range params.Conditions {
+		if cond.Value != want[i] {
+			t.Fatalf("condition %d: expected %q, got %q", i, want[i], cond.Value)
+		}
+		if _, err := schema.Coerce(cond); err != nil {
+			t.Fatalf("condition %d does not parse back: %v", i, err)
+		}
+	}
+}
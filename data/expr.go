@@ -0,0 +1,157 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+)
+
+// FilterExpr is a node of a boolean filter expression. Leaves are
+// FilterConditions; AndExpr, OrExpr and NotExpr combine them.
+type FilterExpr interface {
+	isFilterExpr()
+}
+
+// AndExpr matches when all of its operands match.
+type AndExpr []FilterExpr
+
+// OrExpr matches when any of its operands matches.
+type OrExpr []FilterExpr
+
+// NotExpr negates its operand.
+type NotExpr struct {
+	Expr FilterExpr
+}
+
+func (FilterCondition) isFilterExpr() {}
+func (AndExpr) isFilterExpr()         {}
+func (OrExpr) isFilterExpr()          {}
+func (NotExpr) isFilterExpr()         {}
+
+// MapConditions rebuilds e with every leaf condition replaced by fn(leaf).
+// The first error returned by fn aborts the walk.
+func MapConditions(e FilterExpr, fn func(FilterCondition) (FilterCondition, error)) (FilterExpr, error) {
+	switch e := e.(type) {
+	case FilterCondition:
+		return fn(e)
+	case AndExpr:
+		out, err := mapOperands(e, fn)
+		return AndExpr(out), err
+	case OrExpr:
+		out, err := mapOperands(e, fn)
+		return OrExpr(out), err
+	case NotExpr:
+		inner, err := MapConditions(e.Expr, fn)
+		return NotExpr{Expr: inner}, err
+	case ExistsExpr:
+		inner, err := MapConditions(e.Expr, fn)
+		return ExistsExpr{Join: e.Join, Expr: inner}, err
+	default:
+		return nil, fmt.Errorf("unknown filter expression %T", e)
+	}
+}
+
+func mapOperands(operands []FilterExpr, fn func(FilterCondition) (FilterCondition, error)) ([]FilterExpr, error) {
+	out := make([]FilterExpr, 0, len(operands))
+	for _, operand := range operands {
+		mapped, err := MapConditions(operand, fn)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, mapped)
+	}
+	return out, nil
+}
+
+// filterExpr translates a filter expression into a where expression.
+// Empty AND groups match everything, empty OR groups match nothing.
+func (d Dialect) filterExpr(e FilterExpr) psql.Expression {
+	switch e := e.(type) {
+	case FilterCondition:
+		return d.condition(e)
+	case AndExpr:
+		if len(e) == 0 {
+			return psql.Raw("TRUE")
+		}
+		return psql.And(d.operands(e)...)
+	case OrExpr:
+		if len(e) == 0 {
+			return psql.Raw("FALSE")
+		}
+		return psql.Or(d.operands(e)...)
+	case NotExpr:
+		if e.Expr == nil {
+			return psql.Raw("FALSE")
+		}
+		return psql.Not(d.filterExpr(e.Expr))
+	case ExistsExpr:
+		return d.exists(e)
+	default:
+		return psql.Raw("FALSE")
+	}
+}
+
+// constantExpr reports whether e renders to a constant TRUE or FALSE
+// regardless of the row, folding empty groups the way filterExpr does.
+func constantExpr(e FilterExpr) (value, constant bool) {
+	switch e := e.(type) {
+	case FilterCondition, ExistsExpr:
+		return false, false
+	case AndExpr:
+		return foldOperands(e, true)
+	case OrExpr:
+		return foldOperands(e, false)
+	case NotExpr:
+		if e.Expr == nil {
+			return false, true
+		}
+		value, constant = constantExpr(e.Expr)
+		return !value, constant
+	default:
+		return false, true
+	}
+}
+
+// foldOperands folds an AND (identity true) or OR (identity false) group.
+// An operand equal to the absorbing value decides the group on its own.
+func foldOperands(operands []FilterExpr, identity bool) (value, constant bool) {
+	constant = true
+	for _, operand := range operands {
+		v, c := constantExpr(operand)
+		if c && v != identity {
+			return v, true
+		}
+		constant = constant && c
+	}
+	return identity, constant
+}
+
+func (d Dialect) operands(operands []FilterExpr) []bob.Expression {
+	exprs := make([]bob.Expression, 0, len(operands))
+	for _, operand := range operands {
+		exprs = append(exprs, d.filterExpr(operand))
+	}
+	return exprs
+}
+
+func (e AndExpr) String() string {
+	return "and(" + joinExprs(e) + ")"
+}
+
+func (e OrExpr) String() string {
+	return "or(" + joinExprs(e) + ")"
+}
+
+func (e NotExpr) String() string {
+	return fmt.Sprintf("not(%v)", e.Expr)
+}
+
+func joinExprs(operands []FilterExpr) string {
+	parts := make([]string, 0, len(operands))
+	for _, operand := range operands {
+		parts = append(parts, fmt.Sprint(operand))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,42 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyFilterExprs(t *testing.T) {
+	f := &Filter{
+		Conditions: []FilterCondition{{Column: "name", Mode: Exact, Value: "Focus"}},
+		Exprs: []FilterExpr{
+			OrExpr{
+				FilterCondition{Column: "status", Mode: Exact, Value: "active"},
+				NotExpr{Expr: FilterCondition{Column: "status", Mode: In, Value: "archived,deleted"}},
+			},
+		},
+	}
+
+	sql, args := writeQuery(t, ApplyFilter(habitsQuery(), f))
+	if !strings.Contains(sql, `WHERE ("name" = $1) AND (("status" = $2) OR NOT ("status" IN ($3, $4)))`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+	if len(args) != 4 {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestResolveDropsExprWithUndeclaredColumn(t *testing.T) {
+	schema := NewSchema(Column{Name: "status", Source: "state", Filterable: true})
+	params := QueryParams{Filter: Filter{Exprs: []FilterExpr{
+		OrExpr{FilterCondition{Column: "status", Value: "a"}, FilterCondition{Column: "status", Value: "b"}},
+		NotExpr{Expr: FilterCondition{Column: "secret", Value: "x"}},
+	}}}
+
+	resolved := schema.Resolve(params)
+	if len(resolved.Exprs) != 1 {
+		t.Fatalf("expected one expression, got %v", resolved.Exprs)
+	}
+	if got := resolved.Exprs[0].(OrExpr)[0].(FilterCondition).Column; got != "state" {
+		t.Fatalf("expected leaf column to be resolved, got %q", got)
+	}
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+)
+
+// SelectFields limits the selected columns to params.Fields. With a schema
+// only declared columns are selected and public names resolve to their real
+// columns, qualified with the schema's table if it has one. Without any
+// fields the query is left untouched.
+func SelectFields[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams) *psql.ViewQuery[T, S] {
+	columns := make([]any, 0, len(params.Fields))
+	for _, column := range params.FieldColumns() {
+		columns = append(columns, quoteRef(column))
+	}
+	if len(columns) > 0 {
+		q.Apply(sm.Columns(columns...))
+	}
+	return q
+}
+
+// FieldColumns returns the real columns for the requested fields, leaving
+// out fields the schema does not declare. Without a schema fields that are
+// not plain identifiers (see ValidColumn) are left out.
+func (p *QueryParams) FieldColumns() []string {
+	if p.Schema == nil {
+		return slices.DeleteFunc(slices.Clone(p.Fields), func(field string) bool {
+			return !ValidColumn(field)
+		})
+	}
+	columns := make([]string, 0, len(p.Fields))
+	for _, field := range p.Fields {
+		if column, ok := p.Schema.SelectColumn(field); ok {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// FieldKeys returns the JSON keys to pass to SliceResult.WithFields for the
+// requested fields: their real column names without a table, which is how
+// bob models tag their fields. A public name aliasing another column
+// (Column.Source) yields that column.
+func (p *QueryParams) FieldKeys() []string {
+	columns := p.FieldColumns()
+	if p.Schema == nil {
+		return columns
+	}
+	keys := make([]string, 0, len(columns))
+	for _, column := range columns {
+		keys = append(keys, column[strings.LastIndex(column, ".")+1:])
+	}
+	return keys
+}
+
+// WithFields returns a copy of s whose JSON encoding only keeps the given
+// fields of every item. Fields are matched against the JSON keys of T, see
+// QueryParams.FieldKeys for params parsed against a schema.
+func (s SliceResult[T]) WithFields(fields []string) SliceResult[T] {
+	s.Fields = fields
+	return s
+}
+
+func (s SliceResult[T]) MarshalJSON() ([]byte, error) {
+	type plain SliceResult[T]
+	if len(s.Fields) == 0 {
+		return json.Marshal(plain(s))
+	}
+
+	items := make([]json.RawMessage, 0, len(s.Data))
+	for _, item := range s.Data {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		picked, err := pickFields(raw, s.Fields)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, picked)
+	}
+
+	// The outer Data shadows the one of plain, leaving the rest as is.
+	return json.Marshal(struct {
+		Data []json.RawMessage `json:"data"`
+		plain
+	}{items, plain(s)})
+}
+
+// pickFields keeps only fields of a JSON object, in the requested order.
+// Values that are not objects are returned unchanged.
+func pickFields(raw json.RawMessage, fields []string) (json.RawMessage, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
+		return raw, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	var seen []string
+	for _, field := range fields {
+		value, ok := obj[field]
+		if !ok || slices.Contains(seen, field) {
+			continue
+		}
+		if len(seen) > 0 {
+			buf.WriteByte(',')
+		}
+		seen = append(seen, field)
+		key, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
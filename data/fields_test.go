@@ -0,0 +1,85 @@
+package data
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSelectFields(t *testing.T) {
+	params := &QueryParams{
+		Fields: []string{"id", "title", "secret"},
+		Schema: NewSchema(
+			Column{Name: "id"},
+			Column{Name: "title", Source: "name"},
+		),
+	}
+
+	sql, _ := writeQuery(t, SelectFields(habitsQuery(), params))
+	if !strings.Contains(sql, "SELECT \n"+`"id", "name"`+"\n") {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestSelectFieldsWithTable(t *testing.T) {
+	params := &QueryParams{
+		Fields: []string{"id", "title"},
+		Schema: NewSchema(
+			Column{Name: "id"},
+			Column{Name: "title", Source: "name"},
+		).WithTable("habits"),
+	}
+
+	sql, _ := writeQuery(t, SelectFields(habitsQuery(), params))
+	if !strings.Contains(sql, "SELECT \n"+`"habits"."id", "habits"."name"`+"\n") {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestFieldKeys(t *testing.T) {
+	params := &QueryParams{
+		Fields: []string{"id", "title", "secret"},
+		Schema: NewSchema(
+			Column{Name: "id"},
+			Column{Name: "title", Source: "name"},
+		).WithTable("habits"),
+	}
+	if got := params.FieldKeys(); strings.Join(got, ",") != "id,name" {
+		t.Fatalf("unexpected keys %v", got)
+	}
+
+	params.Schema = nil
+	if got := params.FieldKeys(); strings.Join(got, ",") != "id,title,secret" {
+		t.Fatalf("expected fields unchanged without a schema, got %v", got)
+	}
+
+	params.Fields = []string{"id", `name" FROM secrets --`, "habits.name", "1x"}
+	if got := params.FieldColumns(); strings.Join(got, ",") != "id,habits.name" {
+		t.Fatalf("expected only identifiers without a schema, got %v", got)
+	}
+}
+
+func TestSliceResultWithFields(t *testing.T) {
+	type row struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Code string `json:"code"`
+	}
+	result := Slice([]row{{ID: "1", Name: "Focus", Code: "f-1"}}, 1).WithFields([]string{"code", "id"})
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(raw) != `{"data":[{"code":"f-1","id":"1"}],"total":1}` {
+		t.Fatalf("unexpected json: %s", raw)
+	}
+
+	raw, err = json.Marshal(Slice([]row{{ID: "1"}}, 1))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(raw) != `{"data":[{"id":"1","name":"","code":""}],"total":1}` {
+		t.Fatalf("unexpected json without fields: %s", raw)
+	}
+}
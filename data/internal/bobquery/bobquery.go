@@ -0,0 +1,111 @@
+// Package bobquery applies data.QueryParams to bob queries through the clause
+// types every bob dialect shares. It backs the data/mysql and data/sqlite
+// helpers, which differ only in the data.Dialect they pass.
+package bobquery
+
+import (
+	"strings"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/clause"
+	"github.com/stephenafamo/bob/expr"
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+// Select is the part of a dialect's SelectQuery the helpers modify.
+type Select interface {
+	AppendWhere(e ...any)
+	AppendOrder(order bob.Expression)
+	AppendSelect(columns ...any)
+	AppendJoin(j clause.Join)
+	SetLimit(limit any)
+	SetOffset(offset any)
+}
+
+// Page applies LIMIT and OFFSET, see data.Dialect.PageLimit.
+func Page(d data.Dialect, q Select, pg *data.Pagination) {
+	if limit, ok := d.PageLimit(pg); ok {
+		q.SetLimit(limit)
+	}
+	if pg.Offset > 0 {
+		q.SetOffset(pg.Offset)
+	}
+}
+
+// Order applies every sort key as its own ORDER BY term.
+func Order(d data.Dialect, q Select, s *data.Sort) {
+	for _, def := range d.OrderBy(s) {
+		q.AppendOrder(def)
+	}
+}
+
+// Filter applies the filter conditions and expressions.
+func Filter(d data.Dialect, q Select, f *data.Filter) {
+	for _, e := range d.Where(f) {
+		q.AppendWhere(e)
+	}
+}
+
+// PageByCursor applies keyset pagination, see data.PageByCursor.
+func PageByCursor(d data.Dialect, q Select, params *data.QueryParams) {
+	if where := d.KeysetWhere(params); where != nil {
+		q.AppendWhere(where)
+	}
+	sort := params.KeysetSort()
+	Order(d, q, &sort)
+	if limit, ok := params.PageSize(); ok {
+		q.SetLimit(limit + 1)
+	}
+}
+
+// SelectFields limits the selected columns to params.Fields.
+func SelectFields(q Select, params *data.QueryParams) {
+	columns := make([]any, 0, len(params.Fields))
+	for _, column := range params.FieldColumns() {
+		columns = append(columns, expr.Quote(strings.Split(column, ".")...))
+	}
+	if len(columns) > 0 {
+		q.AppendSelect(columns...)
+	}
+}
+
+// All joins the schema relations params needs and applies every part of
+// params.
+func All(d data.Dialect, q Select, params *data.QueryParams) {
+	if params == nil {
+		return
+	}
+	if params.Schema != nil {
+		for _, j := range params.Schema.Joins(*params) {
+			q.AppendJoin(clause.Join{
+				Type: clause.LeftJoin,
+				To:   clause.TableRef{Expression: expr.Quote(strings.Split(j.Table, ".")...), Alias: j.Alias},
+				On:   []bob.Expression{d.JoinOn(j)},
+			})
+		}
+		resolved := params.Schema.Resolve(*params)
+		params = &resolved
+	}
+	if params.Keyset() {
+		PageByCursor(d, q, params)
+	} else {
+		Page(d, q, &params.Pagination)
+		Order(d, q, &params.Sort)
+	}
+	Filter(d, q, &params.Filter)
+	SelectFields(q, params)
+}
+
+// MutationWhere returns a mod applying f to a DELETE or UPDATE query, see
+// data.DeleteWhere.
+func MutationWhere[Q interface{ AppendWhere(e ...any) }](d data.Dialect, f *data.Filter, opts ...data.MutationOption) (bob.Mod[Q], error) {
+	where, err := d.MutationWhere(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return bob.ModFunc[Q](func(q Q) {
+		for _, e := range where {
+			q.AppendWhere(e)
+		}
+	}), nil
+}
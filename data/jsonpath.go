@@ -0,0 +1,86 @@
+package data
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stephenafamo/bob/dialect/psql"
+)
+
+// PathSep separates a JSON column from its keys in column names, as in
+// "attrs->color". Public names may use a dot instead: "attrs.color".
+const PathSep = "->"
+
+// jsonKey limits the keys that can be written into SQL as literals.
+var jsonKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SplitPath splits "attrs->meta->color" into "attrs" and ["meta", "color"].
+// Names without a valid path are returned whole with no keys.
+func SplitPath(name string) (string, []string) {
+	parts := strings.Split(name, PathSep)
+	if len(parts) < 2 || parts[0] == "" {
+		return name, nil
+	}
+	for _, key := range parts[1:] {
+		if !jsonKey.MatchString(key) {
+			return name, nil
+		}
+	}
+	return parts[0], parts[1:]
+}
+
+// column renders a column name. JSON paths extract their last key as a
+// JSON value, or as text when text is set. Postgres extracts key by key;
+// MySQL and SQLite take a '$.a.b' path.
+func (d Dialect) column(name string, text bool) psql.Expression {
+	column, keys := SplitPath(name)
+	expr := quoteRef(column)
+	if len(keys) == 0 {
+		return expr
+	}
+	last := "->"
+	if text {
+		last = "->>"
+	}
+	if !d.jsonChain {
+		return expr.OP(last, psql.S("$."+strings.Join(keys, ".")))
+	}
+	for i, key := range keys {
+		op := "->"
+		if i == len(keys)-1 {
+			op = last
+		}
+		expr = expr.OP(op, psql.S(key))
+	}
+	return expr
+}
+
+// compared renders a column compared against arg. JSON paths are
+// extracted as text and, on Postgres, cast to the type of the typed
+// argument so numbers and times compare as such.
+func (d Dialect) compared(name string, arg any) psql.Expression {
+	column := d.column(name, true)
+	if _, keys := SplitPath(name); len(keys) == 0 || !d.jsonCasts {
+		return column
+	}
+	switch arg.(type) {
+	case int64, float64:
+		return psql.Cast(column, "numeric")
+	case bool:
+		return psql.Cast(column, "boolean")
+	case time.Time:
+		return psql.Cast(column, "timestamptz")
+	case uuid.UUID:
+		return psql.Cast(column, "uuid")
+	default:
+		return column
+	}
+}
+
+// ordered renders a sort column. Postgres and MySQL order JSON values by
+// type, so numbers sort numerically; SQLite orders the extracted SQL value.
+func (d Dialect) ordered(name string) psql.Expression {
+	return d.column(name, !d.jsonOrder)
+}
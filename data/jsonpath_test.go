@@ -0,0 +1,66 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		keys   []string
+	}{
+		{"attrs->color", "attrs", []string{"color"}},
+		{"attrs->meta->max_score", "attrs", []string{"meta", "max_score"}},
+		{"name", "name", nil},
+		{"attrs->", "attrs->", nil},
+		{"attrs->it's", "attrs->it's", nil},
+	}
+	for _, tt := range tests {
+		column, keys := SplitPath(tt.name)
+		if column != tt.column || !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("SplitPath(%q) = %q, %v", tt.name, column, keys)
+		}
+	}
+}
+
+func TestApplyAllJSONPaths(t *testing.T) {
+	schema := NewSchema(
+		Column{Name: "attrs.color", Filterable: true},
+		Column{Name: "attrs.difficulty", Filterable: true, Sortable: true, Type: TypeInt},
+	)
+	difficulty, err := schema.Coerce(FilterCondition{Column: "attrs->difficulty", Mode: GreaterOrEqual, Value: "3"})
+	if err != nil {
+		t.Fatalf("coerce: %v", err)
+	}
+	params := &QueryParams{
+		Pagination: Pagination{Limit: "ALL"},
+		Filter: Filter{Conditions: []FilterCondition{
+			{Column: "attrs.color", Mode: Exact, Value: "red"},
+			difficulty,
+			{Column: "attrs.secret", Mode: Exact, Value: "x"},
+		}},
+		Sort:   Sort{Keys: []SortKey{{Column: "attrs->difficulty", Direction: "desc"}}},
+		Schema: schema,
+	}
+
+	sql, args := writeQuery(t, ApplyAll(habitsQuery(), params))
+
+	for _, want := range []string{
+		`(("attrs" ->> 'color') = $1)`,
+		`((CAST(("attrs" ->> 'difficulty') AS numeric)) >= $2)`,
+		`ORDER BY ("attrs" -> 'difficulty') DESC`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	if strings.Contains(sql, "secret") {
+		t.Fatalf("undeclared JSON key reached sql: %s", sql)
+	}
+	if len(args) != 2 || args[1] != int64(3) {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
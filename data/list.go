@@ -0,0 +1,247 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/scan"
+)
+
+// ErrKeysetList is returned by List for params carrying a cursor. Keyset
+// pages need the sort key of every row, use PageByCursor and CursorSlice.
+var ErrKeysetList = errors.New("data: List does not support keyset pagination")
+
+// windowTotal is the column the window count is selected as.
+const windowTotal = "_total_count"
+
+type ListOption func(*listConfig)
+
+type listConfig struct {
+	windowCount bool
+	url         *url.URL
+}
+
+// WithWindowCount selects the total with count(*) OVER () alongside the
+// page instead of running a separate COUNT query. Only a page past the end
+// needs the separate query.
+func WithWindowCount() ListOption {
+	return func(c *listConfig) {
+		c.windowCount = true
+	}
+}
+
+// WithURL fills in the next and prev links relative to u, usually r.URL.
+func WithURL(u *url.URL) ListOption {
+	return func(c *listConfig) {
+		c.url = u
+	}
+}
+
+// List runs q with params applied (see ApplyAll) together with the filtered
+// count, and returns the page with its total and page numbers.
+func List[T any, S ~[]T](ctx context.Context, exec bob.Executor, q *psql.ViewQuery[T, S], params *QueryParams, opts ...ListOption) (SliceResult[T], error) {
+	cfg := listConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if params == nil {
+		params = &QueryParams{}
+	}
+	if params.Keyset() {
+		return SliceResult[T]{}, ErrKeysetList
+	}
+
+	count := countQuery(q, params)
+	page := ApplyAll(q, params)
+
+	var (
+		rows  S
+		total int64
+		err   error
+	)
+	if cfg.windowCount {
+		rows, total, err = allWithTotal(ctx, exec, page)
+		if err == nil && len(rows) == 0 && params.Offset > 0 {
+			total, err = count.Count(ctx, exec)
+		}
+	} else {
+		total, err = count.Count(ctx, exec)
+		if err == nil {
+			rows, err = page.All(ctx, exec)
+		}
+	}
+	if err != nil {
+		return SliceResult[T]{}, err
+	}
+
+	res := Slice([]T(rows), total)
+	if cfg.url != nil {
+		return res.WithLinks(cfg.url, params), nil
+	}
+	return res.Paged(params), nil
+}
+
+// countQuery copies q with only the filters and search of params applied.
+func countQuery[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams) *psql.ViewQuery[T, S] {
+	count := &psql.ViewQuery[T, S]{Query: q.Query.Clone()}
+	resolved := *params
+	if params.Schema != nil {
+		if search := params.Schema.search; search != nil && search.enabled(params.Search) {
+			count.Apply(sm.Where(search.match(params.Search)))
+		}
+		count = ApplyJoins(count, params)
+		resolved = params.Schema.Resolve(*params)
+	}
+	return ApplyFilter(count, &resolved.Filter)
+}
+
+// allWithTotal runs q with count(*) OVER () added to the select list and
+// scans it next to the rows.
+func allWithTotal[T any, S ~[]T](ctx context.Context, exec bob.Executor, q *psql.ViewQuery[T, S]) (S, int64, error) {
+	q.Expression.AppendContextualModFunc(func(ctx context.Context, q *dialect.SelectQuery) (context.Context, error) {
+		q.AppendSelect(psql.Raw("count(*) OVER ()").As(windowTotal))
+		return ctx, nil
+	})
+
+	var total int64
+	inner := q.Scanner
+	q.Scanner = func(ctx context.Context, cols []string) (scan.BeforeFunc, func(any) (T, error)) {
+		before, after := inner(ctx, slices.DeleteFunc(slices.Clone(cols), func(c string) bool {
+			return c == windowTotal
+		}))
+		return func(row *scan.Row) (any, error) {
+			row.ScheduleScanByName(windowTotal, &total)
+			return before(row)
+		}, after
+	}
+
+	rows, err := q.All(ctx, exec)
+	return rows, total, err
+}
+
+// Paged returns a copy of s with the page number and page count of an
+// offset page. Without a limit everything is on one page.
+func (s SliceResult[T]) Paged(params *QueryParams) SliceResult[T] {
+	limit, ok := params.PageSize()
+	if !ok || limit == 0 {
+		s.Page, s.PageCount = 1, 1
+		return s
+	}
+	s.Page = params.Offset/limit + 1
+	s.PageCount = (s.Total + limit - 1) / limit
+	return s
+}
+
+// WithLinks returns a copy of s with its page numbers and the next and prev
+// URLs, built from u by replacing the paging params. Keyset results link
+// with after/before, offset results with page or offset, whichever the
+// request used. Requests using page[...] params get links in that syntax.
+func (s SliceResult[T]) WithLinks(u *url.URL, params *QueryParams) SliceResult[T] {
+	if s.NextCursor != "" || s.PrevCursor != "" {
+		if s.NextCursor != "" {
+			s.Next = pageURL(u, params, "after", s.NextCursor)
+		}
+		if s.PrevCursor != "" {
+			s.Prev = pageURL(u, params, "before", s.PrevCursor)
+		}
+		return s
+	}
+
+	s = s.Paged(params)
+	limit, ok := params.PageSize()
+	if !ok || limit == 0 {
+		return s
+	}
+	query := u.Query()
+	byPage := query.Has("page") || query.Has("page[number]")
+	link := func(offset int64) string {
+		if byPage {
+			return pageURL(u, params, "page", strconv.FormatInt(offset/limit+1, 10))
+		}
+		return pageURL(u, params, "offset", strconv.FormatInt(offset, 10))
+	}
+	if next := params.Offset + limit; next < s.Total {
+		s.Next = link(next)
+	}
+	if params.Offset > 0 {
+		s.Prev = link(max(params.Offset-limit, 0))
+	}
+	return s
+}
+
+var (
+	// pagingParams are the page positions pageURL replaces, in both the
+	// default and the bracket syntax.
+	pagingParams = []string{
+		"offset", "page", "after", "cursor", "before",
+		"page[number]", "page[offset]", "page[after]", "page[cursor]", "page[before]",
+	}
+	// sizeParams are the page sizes pageURL sets to the limit of params,
+	// keeping the name the request used.
+	sizeParams = []string{"limit", "page[size]", "page[limit]"}
+	// bracketParams name the params pageURL sets in the bracket syntax.
+	bracketParams = map[string]string{
+		"limit":  "page[size]",
+		"page":   "page[number]",
+		"offset": "page[offset]",
+		"after":  "page[after]",
+		"before": "page[before]",
+	}
+)
+
+// pageURL copies u with the paging params replaced by key=value and the
+// page size of params, in the syntax u uses.
+func pageURL(u *url.URL, params *QueryParams, key, value string) string {
+	query := u.Query()
+	name := func(param string) string { return param }
+	for param := range query {
+		if strings.HasPrefix(param, "page[") {
+			name = func(param string) string { return bracketParams[param] }
+			break
+		}
+	}
+
+	for _, param := range pagingParams {
+		query.Del(param)
+	}
+	if limit, ok := params.PageSize(); ok {
+		size := name("limit")
+		for _, param := range sizeParams {
+			if query.Has(param) {
+				size = param
+				query.Del(param)
+			}
+		}
+		query.Set(size, strconv.FormatInt(limit, 10))
+	}
+	query.Set(name(key), value)
+	next := *u
+	next.RawQuery = query.Encode()
+	return next.String()
+}
+
+// SetHeaders sets X-Total-Count and an RFC 8288 Link header with the next
+// and prev URLs. Call it before writing the response.
+func (s SliceResult[T]) SetHeaders(h http.Header) {
+	h.Set("X-Total-Count", strconv.FormatInt(s.Total, 10))
+	var links []string
+	if s.Next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, s.Next))
+	}
+	if s.Prev != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, s.Prev))
+	}
+	if len(links) > 0 {
+		h.Set("Link", strings.Join(links, ", "))
+	}
+}
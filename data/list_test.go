@@ -0,0 +1,208 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stephenafamo/scan"
+)
+
+// fakeExec answers queries with canned results, in order.
+type fakeExec struct {
+	results []fakeRows
+	queries []string
+}
+
+func (e *fakeExec) QueryContext(_ context.Context, query string, _ ...any) (scan.Rows, error) {
+	e.queries = append(e.queries, query)
+	if len(e.results) == 0 {
+		return nil, errors.New("unexpected query")
+	}
+	rows := e.results[0]
+	e.results = e.results[1:]
+	return &rows, nil
+}
+
+func (e *fakeExec) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	return nil, errors.New("unexpected exec")
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]any
+	next int
+}
+
+func (r *fakeRows) Columns() ([]string, error) { return r.cols, nil }
+func (r *fakeRows) Next() bool                 { r.next++; return r.next <= len(r.rows) }
+func (r *fakeRows) Close() error               { return nil }
+func (r *fakeRows) Err() error                 { return nil }
+
+func (r *fakeRows) Scan(dest ...any) error {
+	row := r.rows[r.next-1]
+	for i, d := range dest {
+		v := reflect.ValueOf(d).Elem()
+		if v.Kind() == reflect.Interface {
+			v.Set(reflect.ValueOf(row[i]))
+			continue
+		}
+		if !reflect.ValueOf(row[i]).CanConvert(v.Type()) {
+			return fmt.Errorf("cannot scan %T into %s", row[i], v.Type())
+		}
+		v.Set(reflect.ValueOf(row[i]).Convert(v.Type()))
+	}
+	return nil
+}
+
+func TestListRunsCountAndPage(t *testing.T) {
+	exec := &fakeExec{results: []fakeRows{
+		{cols: []string{"count"}, rows: [][]any{{int64(45)}}},
+		{cols: []string{"id", "name"}, rows: [][]any{{"1", "gym"}, {"2", "gym run"}}},
+	}}
+	params := &QueryParams{
+		Pagination: Pagination{Limit: int64(20), Offset: 20},
+		Filter:     Filter{Conditions: []FilterCondition{{Column: "name", Mode: Anywhere, Value: "gym"}}},
+		Sort:       Sort{Keys: []SortKey{{Column: "name", Direction: "asc"}}},
+	}
+	u, _ := url.Parse("/api/habits?filter=name_contains=gym&limit=20&offset=20")
+
+	res, err := List(context.Background(), exec, habitsQuery(), params, WithURL(u))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	if len(exec.queries) != 2 {
+		t.Fatalf("expected count and page queries, got %d", len(exec.queries))
+	}
+	count := exec.queries[0]
+	if !strings.Contains(count, "count(1)") || !strings.Contains(count, `WHERE ("name" LIKE $1 ESCAPE '\')`) ||
+		strings.Contains(count, "OFFSET 20") || strings.Contains(count, "ORDER BY") {
+		t.Fatalf("unexpected count query: %s", count)
+	}
+	if !strings.Contains(exec.queries[1], "LIMIT 20") || !strings.Contains(exec.queries[1], "OFFSET 20") {
+		t.Fatalf("unexpected page query: %s", exec.queries[1])
+	}
+
+	if len(res.Data) != 2 || res.Total != 45 || res.Page != 2 || res.PageCount != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Next != "/api/habits?filter=name_contains%3Dgym&limit=20&offset=40" {
+		t.Fatalf("unexpected next: %s", res.Next)
+	}
+	if res.Prev != "/api/habits?filter=name_contains%3Dgym&limit=20&offset=0" {
+		t.Fatalf("unexpected prev: %s", res.Prev)
+	}
+
+	h := http.Header{}
+	res.SetHeaders(h)
+	if h.Get("X-Total-Count") != "45" {
+		t.Fatalf("unexpected X-Total-Count: %q", h.Get("X-Total-Count"))
+	}
+	wantLink := `<` + res.Next + `>; rel="next", <` + res.Prev + `>; rel="prev"`
+	if h.Get("Link") != wantLink {
+		t.Fatalf("unexpected Link: %q", h.Get("Link"))
+	}
+}
+
+func TestListWindowCount(t *testing.T) {
+	exec := &fakeExec{results: []fakeRows{
+		{cols: []string{"id", "name", windowTotal}, rows: [][]any{{"1", "gym", int64(3)}}},
+	}}
+	params := &QueryParams{Pagination: Pagination{Limit: int64(1)}}
+
+	res, err := List(context.Background(), exec, habitsQuery(), params, WithWindowCount())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(exec.queries) != 1 || !strings.Contains(exec.queries[0], `count(*) OVER () AS "_total_count"`) {
+		t.Fatalf("expected a single query with a window count: %v", exec.queries)
+	}
+	if len(res.Data) != 1 || res.Data[0].Name != "gym" || res.Total != 3 || res.PageCount != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestListWindowCountPastLastPage(t *testing.T) {
+	exec := &fakeExec{results: []fakeRows{
+		{cols: []string{"id", "name", windowTotal}},
+		{cols: []string{"count"}, rows: [][]any{{int64(3)}}},
+	}}
+	params := &QueryParams{Pagination: Pagination{Limit: int64(10), Offset: 50}}
+
+	res, err := List(context.Background(), exec, habitsQuery(), params, WithWindowCount())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(exec.queries) != 2 || res.Total != 3 {
+		t.Fatalf("expected a fallback count query, got total %d from %v", res.Total, exec.queries)
+	}
+}
+
+func TestListRejectsKeyset(t *testing.T) {
+	params := &QueryParams{Pagination: Pagination{After: &Cursor{Values: []any{"1"}}}}
+	if _, err := List(context.Background(), &fakeExec{}, habitsQuery(), params); !errors.Is(err, ErrKeysetList) {
+		t.Fatalf("expected ErrKeysetList, got %v", err)
+	}
+}
+
+func TestWithLinksByPageAndCursor(t *testing.T) {
+	params := &QueryParams{Pagination: Pagination{Limit: int64(10), Offset: 10}}
+	u, _ := url.Parse("/api/habits?limit=10&page=2")
+
+	res := Slice([]habit{}, 25).WithLinks(u, params)
+	if res.Next != "/api/habits?limit=10&page=3" || res.Prev != "/api/habits?limit=10&page=1" {
+		t.Fatalf("unexpected links: %q %q", res.Next, res.Prev)
+	}
+
+	keyset := SliceResult[habit]{NextCursor: "abc"}.WithLinks(u, params)
+	if keyset.Next != "/api/habits?after=abc&limit=10" || keyset.Prev != "" {
+		t.Fatalf("unexpected cursor links: %q %q", keyset.Next, keyset.Prev)
+	}
+}
+
+func TestWithLinksBracketSyntax(t *testing.T) {
+	params := &QueryParams{Pagination: Pagination{Limit: int64(10), Offset: 10}}
+	link := func(raw string) string {
+		t.Helper()
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unescaped, err := url.QueryUnescape(u.RawQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Path + "?" + unescaped
+	}
+
+	u, _ := url.Parse("/api/habits?filter[name][contains]=gym&page[size]=10&page[number]=2")
+	res := Slice([]habit{}, 25).WithLinks(u, params)
+	if got := link(res.Next); got != "/api/habits?filter[name][contains]=gym&page[number]=3&page[size]=10" {
+		t.Fatalf("unexpected next: %s", got)
+	}
+	if got := link(res.Prev); got != "/api/habits?filter[name][contains]=gym&page[number]=1&page[size]=10" {
+		t.Fatalf("unexpected prev: %s", got)
+	}
+
+	u, _ = url.Parse("/api/habits?page[limit]=10&page[offset]=10")
+	res = Slice([]habit{}, 25).WithLinks(u, params)
+	if got := link(res.Next); got != "/api/habits?page[limit]=10&page[offset]=20" {
+		t.Fatalf("unexpected offset next: %s", got)
+	}
+
+	u, _ = url.Parse("/api/habits?page[size]=10&page[after]=abc")
+	keyset := SliceResult[habit]{NextCursor: "def", PrevCursor: "xyz"}.WithLinks(u, params)
+	if got := link(keyset.Next); got != "/api/habits?page[after]=def&page[size]=10" {
+		t.Fatalf("unexpected cursor next: %s", got)
+	}
+	if got := link(keyset.Prev); got != "/api/habits?page[before]=xyz&page[size]=10" {
+		t.Fatalf("unexpected cursor prev: %s", got)
+	}
+}
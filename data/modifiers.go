@@ -2,14 +2,41 @@ package data
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/stephenafamo/bob"
 	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
 	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/expr"
 )
 
 type Pagination struct {
 	Limit  any // number or "ALL"
 	Offset int64
+
+	// After and Before switch to keyset pagination, see PageByCursor.
+	After  *Cursor
+	Before *Cursor
+}
+
+// PageSize returns the numeric page size, or false for "ALL".
+func (p Pagination) PageSize() (int64, bool) {
+	switch l := p.Limit.(type) {
+	case int64:
+		return l, true
+	case int:
+		return int64(l), true
+	case int32:
+		return int64(l), true
+	default:
+		return 0, false
+	}
+}
+
+// Keyset reports whether the pagination uses cursors rather than an offset.
+func (p Pagination) Keyset() bool {
+	return p.After != nil || p.Before != nil
 }
 
 func Slice[T any](data []T, count int64) SliceResult[T] {
@@ -22,37 +49,93 @@ func Slice[T any](data []T, count int64) SliceResult[T] {
 type SliceResult[T any] struct {
 	Data  []T   `json:"data"`
 	Total int64 `json:"total"`
+
+	// Set by CursorSlice for keyset pagination.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+
+	// Set by Paged and WithLinks.
+	Page      int64  `json:"page,omitempty"`
+	PageCount int64  `json:"page_count,omitempty"`
+	Next      string `json:"next,omitempty"`
+	Prev      string `json:"prev,omitempty"`
+
+	// Fields restricts the JSON encoding of Data, see WithFields.
+	Fields []string `json:"-"`
 }
 
+// Sort is an ordered list of sort keys, each emitted as its own ORDER BY term.
 type Sort struct {
+	Keys []SortKey
+}
+
+type SortKey struct {
 	Column    string
-	Direction string
+	Direction string // "asc" or "desc"
+	Nulls     string // "first", "last" or "" for the database default
 }
 
 type FilterCondition struct {
 	Column string
 	Mode   MatchMode
 	Value  string
+
+	// IgnoreCase compares text case-insensitively, with any mode.
+	IgnoreCase bool
+
+	// Args holds the typed values parsed from Value by Schema.Coerce.
+	// When set they are bound instead of the raw string values.
+	Args []any
 }
 
 type Filter struct {
 	Conditions []FilterCondition
+
+	// Exprs holds boolean filter expressions. They are ANDed with each
+	// other and with Conditions.
+	Exprs []FilterExpr
 }
 
 type QueryParams struct {
 	Pagination
 	Filter
 	Sort
+
+	// Fields lists the columns requested with fields=, see SelectFields.
+	Fields []string
+
+	// Search is the full-text query from q=, see ApplySearch.
+	Search string
+
+	// GroupBy and Aggregates come from group_by= and agg=, see
+	// ApplyAggregate.
+	GroupBy    []GroupKey
+	Aggregates []Aggregate
+
+	// Schema restricts which columns may be filtered and sorted on.
+	// When nil, column names are used as given.
+	Schema *Schema
 }
 
 type MatchMode int
 
 const (
 	Exact           MatchMode = iota // Exact = 0
-	CaseInsensitive                  // CaseInsensitive = 1
-	Anywhere                         // Anywhere = 2
-	Start                            // Start = 3
-	End                              // End = 4
+	CaseInsensitive                  // CaseInsensitive = 1, Exact ignoring case
+	Anywhere                         // Anywhere = 2, contains the value
+	Start                            // Start = 3, starts with the value
+	End                              // End = 4, ends with the value
+	NotEqual                         // NotEqual = 5
+	GreaterThan                      // GreaterThan = 6
+	GreaterOrEqual                   // GreaterOrEqual = 7
+	LessThan                         // LessThan = 8
+	LessOrEqual                      // LessOrEqual = 9
+	In                               // In = 10, comma separated values
+	NotIn                            // NotIn = 11, comma separated values
+	Between                          // Between = 12, two comma separated bounds
+	IsNull                           // IsNull = 13, value is ignored
+	NotNull                          // NotNull = 14, value is ignored
+	Within                           // Within = 15, half-open range [start, end) of two comma separated bounds
 )
 
 func Page[T any, S ~[]T](q *psql.ViewQuery[T, S], pg *Pagination) *psql.ViewQuery[T, S] {
@@ -61,11 +144,8 @@ func Page[T any, S ~[]T](q *psql.ViewQuery[T, S], pg *Pagination) *psql.ViewQuer
 }
 
 func Order[T any, S ~[]T](q *psql.ViewQuery[T, S], s *Sort) *psql.ViewQuery[T, S] {
-	if s.Direction == "asc" {
-		q.Apply(sm.OrderBy(s.Column).Asc())
-	}
-	if s.Direction == "desc" {
-		q.Apply(sm.OrderBy(s.Column).Desc())
+	for _, def := range Postgres.OrderBy(s) {
+		q.Expression.AppendOrder(def)
 	}
 	return q
 }
@@ -74,44 +154,218 @@ func Order[T any, S ~[]T](q *psql.ViewQuery[T, S], s *Sort) *psql.ViewQuery[T, S
 // If no conditions are provided, it does not apply any filters.
 func ApplyFilter[T any, S ~[]T](q *psql.ViewQuery[T, S], f *Filter) *psql.ViewQuery[T, S] {
 	// Check if there are any conditions to apply
-	if len(f.Conditions) == 0 {
+	if len(f.Conditions) == 0 && len(f.Exprs) == 0 {
 		// No filter conditions, return the query as is
 		return q
 	}
 
 	// Apply each filter condition
-	for _, condition := range f.Conditions {
+	for _, e := range Postgres.Where(f) {
+		q.Apply(sm.Where(e))
+	}
+	return q
+}
+
+// condition translates a single filter condition into a where expression.
+// Malformed list conditions evaluate to FALSE rather than being skipped, so a
+// bad filter never widens the result set.
+func (d Dialect) condition(condition FilterCondition) psql.Expression {
+	args := condition.args()
+	var first any
+	if len(args) > 0 {
+		first = args[0]
+	}
+	column := d.compared(condition.Column, first)
+	if condition.IgnoreCase && !condition.Mode.Pattern() {
+		column, args = foldCase(column, args)
+	} else if d.binary && allText(args) {
 		switch condition.Mode {
-		case Exact:
-			q.Apply(sm.Where(psql.Quote(condition.Column).EQ(psql.Arg(condition.Value))))
-		case CaseInsensitive:
-			q.Apply(sm.Where(psql.Quote(condition.Column).ILike(psql.Arg(condition.Value))))
-		case Start:
-			q.Apply(sm.Where(psql.Quote(condition.Column).ILike(psql.Arg("%" + condition.Value))))
-		case Anywhere:
-			q.Apply(sm.Where(psql.Quote(condition.Column).ILike(psql.Arg("%" + condition.Value + "%"))))
-		case End:
-			q.Apply(sm.Where(psql.Quote(condition.Column).ILike(psql.Arg(condition.Value + "%"))))
-		default:
-			q.Apply(sm.Where(psql.Quote(condition.Column).ILike(psql.Arg(condition.Value))))
+		case Exact, NotEqual, In, NotIn:
+			column = dialect.NewExpression(expr.Join{Exprs: []bob.Expression{psql.Raw("BINARY"), column}})
 		}
 	}
-	return q
+	switch condition.Mode {
+	case IsNull:
+		return column.IsNull()
+	case NotNull:
+		return column.IsNotNull()
+	case In, NotIn:
+		if len(args) == 0 {
+			return psql.Raw("FALSE")
+		}
+		exprs := make([]bob.Expression, 0, len(args))
+		for _, v := range args {
+			exprs = append(exprs, psql.Arg(v))
+		}
+		if condition.Mode == NotIn {
+			return column.NotIn(exprs...)
+		}
+		return column.In(exprs...)
+	case Between:
+		if len(args) != 2 {
+			return psql.Raw("FALSE")
+		}
+		return column.Between(psql.Arg(args[0]), psql.Arg(args[1]))
+	case Within:
+		if len(args) != 2 {
+			return psql.Raw("FALSE")
+		}
+		return psql.And(column.GTE(psql.Arg(args[0])), column.LT(psql.Arg(args[1])))
+	}
+
+	if len(args) != 1 {
+		return psql.Raw("FALSE")
+	}
+	switch condition.Mode {
+	case Exact:
+		return column.EQ(psql.Arg(args[0]))
+	case NotEqual:
+		return column.NE(psql.Arg(args[0]))
+	case GreaterThan:
+		return column.GT(psql.Arg(args[0]))
+	case GreaterOrEqual:
+		return column.GTE(psql.Arg(args[0]))
+	case LessThan:
+		return column.LT(psql.Arg(args[0]))
+	case LessOrEqual:
+		return column.LTE(psql.Arg(args[0]))
+	default:
+		return d.match(column, condition)
+	}
+}
+
+// Pattern reports whether m matches text with LIKE: CaseInsensitive, Start,
+// End and Anywhere.
+func (m MatchMode) Pattern() bool {
+	switch m {
+	case CaseInsensitive, Start, End, Anywhere:
+		return true
+	default:
+		return false
+	}
+}
+
+// foldCase lowers the column and arguments of a case-insensitive
+// comparison. Typed arguments are compared as they are.
+func foldCase(column psql.Expression, args []any) (psql.Expression, []any) {
+	folded := make([]any, 0, len(args))
+	for _, arg := range args {
+		text, ok := arg.(string)
+		if !ok {
+			return column, args
+		}
+		folded = append(folded, strings.ToLower(text))
+	}
+	return dialect.NewExpression(psql.F("lower", column)()), folded
+}
+
+// match renders a pattern mode. The value is escaped, so % and _ in it
+// match themselves.
+func (d Dialect) match(column psql.Expression, c FilterCondition) psql.Expression {
+	if !c.ignoresCase() && d.glob {
+		return column.OP(d.like, psql.Arg(c.globPattern()))
+	}
+	op := d.like
+	if c.ignoresCase() {
+		op = d.ilike
+	}
+	return column.OP(op, expr.Join{Exprs: []bob.Expression{
+		psql.Arg(c.pattern()),
+		psql.Raw("ESCAPE " + d.escape),
+	}})
+}
+
+func (c FilterCondition) ignoresCase() bool {
+	return c.IgnoreCase || c.Mode == CaseInsensitive
+}
+
+var (
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	globEscaper = strings.NewReplacer(`[`, `[[]`, `*`, `[*]`, `?`, `[?]`)
+)
+
+// pattern returns the escaped LIKE pattern of a pattern mode, with \ as the
+// escape character. ApplyToSlice matches the same patterns.
+func (c FilterCondition) pattern() string {
+	return wildcards(c.Mode, likeEscaper.Replace(c.Value), "%")
+}
+
+// globPattern is pattern for GLOB, which escapes with brackets.
+func (c FilterCondition) globPattern() string {
+	return wildcards(c.Mode, globEscaper.Replace(c.Value), "*")
+}
+
+func wildcards(mode MatchMode, value, wild string) string {
+	switch mode {
+	case Start:
+		return value + wild
+	case End:
+		return wild + value
+	case Anywhere:
+		return wild + value + wild
+	default:
+		return value
+	}
+}
+
+// args returns the values bound for the condition: the typed Args when the
+// schema coerced them, the raw string values otherwise.
+func (c FilterCondition) args() []any {
+	if c.Args != nil {
+		return c.Args
+	}
+	values := c.Values()
+	args := make([]any, 0, len(values))
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return args
+}
+
+// Values splits the condition value into its comma separated parts for the
+// list modes (In, NotIn, Between, Within). Other modes yield the value as is.
+func (c FilterCondition) Values() []string {
+	switch c.Mode {
+	case In, NotIn, Between, Within:
+	default:
+		return []string{c.Value}
+	}
+	if c.Value == "" {
+		return nil
+	}
+	parts := strings.Split(c.Value, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
 }
 
 func ApplyAll[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams) *psql.ViewQuery[T, S] {
 	if params == nil { // shity but w/e
 		return q
 	}
-	q = Page(q, &params.Pagination)
-	q = Order(q, &params.Sort)
+	if params.Schema != nil {
+		if search := params.Schema.search; search != nil {
+			q = ApplySearch(q, params, *search)
+		}
+		q = ApplyJoins(q, params)
+		resolved := params.Schema.Resolve(*params)
+		params = &resolved
+	}
+	if params.Keyset() {
+		q = PageByCursor(q, params)
+	} else {
+		q = Page(q, &params.Pagination)
+		q = Order(q, &params.Sort)
+	}
 	q = ApplyFilter(q, &params.Filter)
+	q = SelectFields(q, params)
 	return q
 }
 
 func (qp QueryParams) String() string {
-	return fmt.Sprintf("QueryParams: [Sort: column: %s, direction: %s], [Filter: conditions: %v], [Page: offset: %d, limit: %d]",
-		qp.Column, qp.Direction,
+	return fmt.Sprintf("QueryParams: [Sort: keys: %v], [Filter: conditions: %v], [Page: offset: %d, limit: %d]",
+		qp.Keys,
 		qp.Conditions,
 		qp.Offset, qp.Limit)
 }
@@ -132,6 +386,28 @@ func (m MatchMode) String() string {
 		return "Anywhere"
 	case End:
 		return "End"
+	case NotEqual:
+		return "NotEqual"
+	case GreaterThan:
+		return "GreaterThan"
+	case GreaterOrEqual:
+		return "GreaterOrEqual"
+	case LessThan:
+		return "LessThan"
+	case LessOrEqual:
+		return "LessOrEqual"
+	case In:
+		return "In"
+	case NotIn:
+		return "NotIn"
+	case Between:
+		return "Between"
+	case IsNull:
+		return "IsNull"
+	case NotNull:
+		return "NotNull"
+	case Within:
+		return "Within"
 	default:
 		return "Unknown"
 	}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyFilterOperators(t *testing.T) {
+	f := &Filter{Conditions: []FilterCondition{
+		{Column: "score", Mode: GreaterThan, Value: "3"},
+		{Column: "status", Mode: In, Value: "active, archived"},
+		{Column: "completed_at", Mode: Between, Value: "2024-01-01,2024-02-01"},
+		{Column: "deleted_at", Mode: IsNull},
+		{Column: "name", Mode: NotEqual, Value: "x"},
+	}}
+
+	sql, args := writeQuery(t, ApplyFilter(habitsQuery(), f))
+
+	for _, want := range []string{
+		`("score" > $1)`,
+		`("status" IN ($2, $3))`,
+		`"completed_at" BETWEEN $4 AND $5`,
+		`"deleted_at" IS NULL`,
+		`("name" <> $6)`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	if len(args) != 6 || args[1] != "active" || args[2] != "archived" {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestApplyFilterMalformedBetween(t *testing.T) {
+	f := &Filter{Conditions: []FilterCondition{
+		{Column: "completed_at", Mode: Between, Value: "2024-01-01"},
+	}}
+
+	sql, _ := writeQuery(t, ApplyFilter(habitsQuery(), f))
+	if !strings.Contains(sql, "WHERE FALSE") {
+		t.Fatalf("expected malformed between to match nothing: %s", sql)
+	}
+}
+
+func TestOrderMultipleKeys(t *testing.T) {
+	s := &Sort{Keys: []SortKey{
+		{Column: "completed_at", Direction: "desc", Nulls: "last"},
+		{Column: "name", Direction: "asc"},
+	}}
+
+	sql, _ := writeQuery(t, Order(habitsQuery(), s))
+	if !strings.Contains(sql, `ORDER BY "completed_at" DESC NULLS LAST, "name" ASC`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestApplyFilterPatterns(t *testing.T) {
+	f := &Filter{Conditions: []FilterCondition{
+		{Column: "name", Mode: Start, Value: "100%"},
+		{Column: "name", Mode: End, Value: "_x"},
+		{Column: "name", Mode: Anywhere, Value: `a\b`, IgnoreCase: true},
+		{Column: "name", Mode: CaseInsensitive, Value: "Gym"},
+		{Column: "status", Mode: In, Value: "Active,PAUSED", IgnoreCase: true},
+	}}
+
+	sql, args := writeQuery(t, ApplyFilter(habitsQuery(), f))
+
+	for _, want := range []string{
+		`("name" LIKE $1 ESCAPE '\')`,
+		`("name" LIKE $2 ESCAPE '\')`,
+		`("name" ILIKE $3 ESCAPE '\')`,
+		`("name" ILIKE $4 ESCAPE '\')`,
+		`(lower("status") IN ($5, $6))`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	want := []any{`100\%%`, `%\_x`, `%a\\b%`, "Gym", "active", "paused"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+)
+
+var (
+	// ErrUnfilteredMutation is returned when a bulk UPDATE or DELETE would run
+	// without any filter and AllowUnfiltered was not given.
+	ErrUnfilteredMutation = errors.New("refusing to update or delete without a filter")
+	// ErrMutationFilter is returned when a condition of a bulk UPDATE or
+	// DELETE cannot be resolved against the schema given with MutationSchema.
+	ErrMutationFilter = errors.New("filter cannot be applied to a mutation")
+)
+
+type MutationOption func(*mutationConfig)
+
+type mutationConfig struct {
+	allowUnfiltered bool
+	schema          *Schema
+}
+
+// AllowUnfiltered lets an empty filter through, so the mutation affects
+// every row of the table.
+func AllowUnfiltered() MutationOption {
+	return func(c *mutationConfig) {
+		c.allowUnfiltered = true
+	}
+}
+
+// MutationSchema resolves the filter against s before the where clause is
+// built. Unlike Schema.Resolve it never drops a condition: undeclared or
+// non-filterable columns and relation paths, which an UPDATE or DELETE
+// cannot join, fail with ErrMutationFilter.
+func MutationSchema(s *Schema) MutationOption {
+	return func(c *mutationConfig) {
+		c.schema = s
+	}
+}
+
+// MutationWhere returns the where expressions for a bulk UPDATE or DELETE.
+// An empty filter, or one whose expressions are always true, is refused
+// with ErrUnfilteredMutation unless AllowUnfiltered is given. Filters parsed
+// against a schema should be resolved with MutationSchema rather than
+// Schema.Resolve, which silently drops what it cannot resolve.
+func (d Dialect) MutationWhere(f *Filter, opts ...MutationOption) ([]bob.Expression, error) {
+	cfg := mutationConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if f == nil {
+		f = &Filter{}
+	}
+	if cfg.schema != nil {
+		resolved, err := cfg.schema.resolveMutation(f)
+		if err != nil {
+			return nil, err
+		}
+		f = resolved
+	}
+	if !cfg.allowUnfiltered && !f.restricts() {
+		return nil, ErrUnfilteredMutation
+	}
+	return d.Where(f), nil
+}
+
+// resolveMutation qualifies every condition of f, failing on any condition
+// Resolve would drop and on relation paths.
+func (s *Schema) resolveMutation(f *Filter) (*Filter, error) {
+	resolve := func(cond FilterCondition) (FilterCondition, error) {
+		ref, ok := s.lookupRef(cond.Column)
+		switch {
+		case !ok || !ref.column.Filterable:
+			return cond, fmt.Errorf("%w: column %q is not filterable", ErrMutationFilter, cond.Column)
+		case len(ref.path) > 0:
+			return cond, fmt.Errorf("%w: column %q is on a relation", ErrMutationFilter, cond.Column)
+		}
+		cond.Column = s.qualify(ref)
+		return cond, nil
+	}
+
+	out := &Filter{}
+	for _, cond := range f.Conditions {
+		resolved, err := resolve(cond)
+		if err != nil {
+			return nil, err
+		}
+		out.Conditions = append(out.Conditions, resolved)
+	}
+	for _, e := range f.Exprs {
+		resolved, err := MapConditions(e, resolve)
+		if err != nil {
+			return nil, err
+		}
+		out.Exprs = append(out.Exprs, resolved)
+	}
+	return out, nil
+}
+
+// restricts reports whether f can exclude any row: it has a condition, or an
+// expression that is not always true like an empty AndExpr.
+func (f *Filter) restricts() bool {
+	if len(f.Conditions) > 0 {
+		return true
+	}
+	for _, e := range f.Exprs {
+		if value, constant := constantExpr(e); !constant || !value {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteWhere returns a mod applying f to a bob DELETE query:
+//
+//	mod, err := data.DeleteWhere(&params.Filter)
+//	psql.Delete(dm.From("habits"), mod)
+func DeleteWhere(f *Filter, opts ...MutationOption) (bob.Mod[*dialect.DeleteQuery], error) {
+	where, err := Postgres.MutationWhere(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	mods := make(bob.Mods[*dialect.DeleteQuery], 0, len(where))
+	for _, e := range where {
+		mods = append(mods, dm.Where(e))
+	}
+	return mods, nil
+}
+
+// UpdateWhere returns a mod applying f to a bob UPDATE query.
+func UpdateWhere(f *Filter, opts ...MutationOption) (bob.Mod[*dialect.UpdateQuery], error) {
+	where, err := Postgres.MutationWhere(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	mods := make(bob.Mods[*dialect.UpdateQuery], 0, len(where))
+	for _, e := range where {
+		mods = append(mods, um.Where(e))
+	}
+	return mods, nil
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dm"
+	"github.com/stephenafamo/bob/dialect/psql/um"
+)
+
+func TestDeleteWhere(t *testing.T) {
+	f := &Filter{Conditions: []FilterCondition{
+		{Column: "status", Mode: Exact, Value: "archived"},
+		{Column: "score", Mode: LessThan, Value: "2"},
+	}}
+
+	mod, err := DeleteWhere(f)
+	if err != nil {
+		t.Fatalf("delete where: %v", err)
+	}
+
+	var buf bytes.Buffer
+	args, err := psql.Delete(dm.From("habits"), mod).WriteQuery(context.Background(), &buf, 1)
+	if err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	sql := buf.String()
+	if !strings.Contains(sql, `WHERE ("status" = $1) AND ("score" < $2)`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+	if len(args) != 2 || args[0] != "archived" {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestUpdateWhere(t *testing.T) {
+	f := &Filter{Exprs: []FilterExpr{OrExpr{
+		FilterCondition{Column: "status", Mode: Exact, Value: "active"},
+		FilterCondition{Column: "deleted_at", Mode: NotNull},
+	}}}
+
+	mod, err := UpdateWhere(f)
+	if err != nil {
+		t.Fatalf("update where: %v", err)
+	}
+
+	var buf bytes.Buffer
+	_, err = psql.Update(um.Table("habits"), um.SetCol("status").ToArg("archived"), mod).
+		WriteQuery(context.Background(), &buf, 1)
+	if err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	if sql := buf.String(); !strings.Contains(sql, `WHERE (("status" = $2) OR ("deleted_at" IS NOT NULL))`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestMutationRefusesEmptyFilter(t *testing.T) {
+	if _, err := DeleteWhere(&Filter{}); !errors.Is(err, ErrUnfilteredMutation) {
+		t.Fatalf("expected ErrUnfilteredMutation, got %v", err)
+	}
+	if _, err := UpdateWhere(nil); !errors.Is(err, ErrUnfilteredMutation) {
+		t.Fatalf("expected ErrUnfilteredMutation, got %v", err)
+	}
+
+	for _, e := range []FilterExpr{
+		AndExpr{},
+		NotExpr{Expr: OrExpr{}},
+		OrExpr{AndExpr{}, FilterCondition{Column: "status", Mode: Exact, Value: "archived"}},
+	} {
+		if _, err := DeleteWhere(&Filter{Exprs: []FilterExpr{e}}); !errors.Is(err, ErrUnfilteredMutation) {
+			t.Fatalf("expected ErrUnfilteredMutation for %v, got %v", e, err)
+		}
+	}
+	if _, err := UpdateWhere(&Filter{Exprs: []FilterExpr{AndExpr{}, OrExpr{}}}); err != nil {
+		t.Fatalf("expected a FALSE expression to restrict: %v", err)
+	}
+
+	mod, err := DeleteWhere(&Filter{}, AllowUnfiltered())
+	if err != nil {
+		t.Fatalf("expected opt-in to allow empty filter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := psql.Delete(dm.From("habits"), mod).WriteQuery(context.Background(), &buf, 1); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	if strings.Contains(buf.String(), "WHERE") {
+		t.Fatalf("expected no WHERE clause: %s", buf.String())
+	}
+}
+
+func TestMutationSchema(t *testing.T) {
+	schema := NewSchema(
+		Column{Name: "status", Filterable: true},
+		Column{Name: "score", Source: "total_score", Filterable: true},
+		Column{Name: "notes"},
+	).WithRelations(
+		Relation{Name: "group", Table: "habit_groups", LocalKey: "group_id", ForeignKey: "id",
+			Schema: NewSchema(Column{Name: "name", Filterable: true})},
+	)
+
+	f := &Filter{
+		Conditions: []FilterCondition{{Column: "status", Mode: Exact, Value: "archived"}},
+		Exprs:      []FilterExpr{NotExpr{Expr: FilterCondition{Column: "score", Mode: LessThan, Value: "2"}}},
+	}
+	mod, err := DeleteWhere(f, MutationSchema(schema))
+	if err != nil {
+		t.Fatalf("delete where: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := psql.Delete(dm.From("habits"), mod).WriteQuery(context.Background(), &buf, 1); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	if sql := buf.String(); !strings.Contains(sql, `WHERE ("status" = $1) AND NOT ("total_score" < $2)`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+
+	for _, f := range []*Filter{
+		{Conditions: []FilterCondition{
+			{Column: "status", Mode: Exact, Value: "archived"},
+			{Column: "secret", Mode: Exact, Value: "1"},
+		}},
+		{Conditions: []FilterCondition{{Column: "notes", Mode: Anywhere, Value: "x"}}},
+		{Exprs: []FilterExpr{OrExpr{FilterCondition{Column: "secret", Mode: Exact, Value: "1"}}}},
+		{Conditions: []FilterCondition{{Column: "group.name", Mode: Exact, Value: "health"}}},
+	} {
+		if _, err := UpdateWhere(f, MutationSchema(schema)); !errors.Is(err, ErrMutationFilter) {
+			t.Fatalf("expected ErrMutationFilter for %+v, got %v", f, err)
+		}
+	}
+}
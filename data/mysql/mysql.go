@@ -0,0 +1,48 @@
+// Package mysql applies data.QueryParams to bob MySQL queries. It mirrors the
+// Postgres helpers in package data.
+package mysql
+
+import (
+	"github.com/stephenafamo/bob/dialect/mysql"
+	"github.com/tschuyebuhl/httpkit/data"
+	"github.com/tschuyebuhl/httpkit/data/internal/bobquery"
+)
+
+// Page applies LIMIT and OFFSET. MySQL has no LIMIT ALL, so "ALL" omits the
+// LIMIT, or uses the largest possible one when there is an offset.
+func Page[T any, S ~[]T](q *mysql.ViewQuery[T, S], pg *data.Pagination) *mysql.ViewQuery[T, S] {
+	bobquery.Page(data.MySQL, q.Expression, pg)
+	return q
+}
+
+// Order applies every sort key as its own ORDER BY term. NULLS FIRST/LAST is
+// emulated with an extra "column IS NULL" term.
+func Order[T any, S ~[]T](q *mysql.ViewQuery[T, S], s *data.Sort) *mysql.ViewQuery[T, S] {
+	bobquery.Order(data.MySQL, q.Expression, s)
+	return q
+}
+
+// ApplyFilter applies the filter conditions and expressions. Pattern modes
+// use LIKE, which is case-insensitive under the default collations.
+func ApplyFilter[T any, S ~[]T](q *mysql.ViewQuery[T, S], f *data.Filter) *mysql.ViewQuery[T, S] {
+	bobquery.Filter(data.MySQL, q.Expression, f)
+	return q
+}
+
+// PageByCursor applies keyset pagination, see data.PageByCursor.
+func PageByCursor[T any, S ~[]T](q *mysql.ViewQuery[T, S], params *data.QueryParams) *mysql.ViewQuery[T, S] {
+	bobquery.PageByCursor(data.MySQL, q.Expression, params)
+	return q
+}
+
+// SelectFields limits the selected columns to params.Fields, see
+// data.SelectFields.
+func SelectFields[T any, S ~[]T](q *mysql.ViewQuery[T, S], params *data.QueryParams) *mysql.ViewQuery[T, S] {
+	bobquery.SelectFields(q.Expression, params)
+	return q
+}
+
+func ApplyAll[T any, S ~[]T](q *mysql.ViewQuery[T, S], params *data.QueryParams) *mysql.ViewQuery[T, S] {
+	bobquery.All(data.MySQL, q.Expression, params)
+	return q
+}
@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stephenafamo/bob/dialect/mysql"
+	"github.com/stephenafamo/bob/expr"
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+type habit struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestApplyAll(t *testing.T) {
+	q := mysql.NewView[habit]("habits", expr.NewColumnsExpr("id", "name")).Query()
+	params := &data.QueryParams{
+		Pagination: data.Pagination{Limit: "ALL", Offset: 40},
+		Filter: data.Filter{Conditions: []data.FilterCondition{
+			{Column: "name", Mode: data.Anywhere, Value: "gym"},
+			{Column: "name", Mode: data.Start, Value: "50%", IgnoreCase: true},
+		}},
+		Sort: data.Sort{Keys: []data.SortKey{{Column: "completed_at", Direction: "desc", Nulls: "last"}}},
+	}
+
+	var buf bytes.Buffer
+	args, err := ApplyAll(q, params).WriteQuery(context.Background(), &buf, 1)
+	if err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+
+	sql := buf.String()
+	for _, want := range []string{
+		"WHERE (`name` LIKE BINARY ? ESCAPE '\\\\') AND (`name` LIKE ? ESCAPE '\\\\')",
+		"ORDER BY (`completed_at` IS NULL) ASC, `completed_at` DESC",
+		"LIMIT 9223372036854775807",
+		"OFFSET 40",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	if len(args) != 2 || args[0] != "%gym%" || args[1] != `50\%%` {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestSelectFieldsWithTable(t *testing.T) {
+	q := mysql.NewView[habit]("habits", expr.NewColumnsExpr("id", "name")).Query()
+	params := &data.QueryParams{
+		Fields: []string{"id", "title"},
+		Schema: data.NewSchema(
+			data.Column{Name: "id"},
+			data.Column{Name: "title", Source: "name"},
+		).WithTable("habits"),
+	}
+
+	var buf bytes.Buffer
+	if _, err := SelectFields(q, params).WriteQuery(context.Background(), &buf, 1); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	if want := "`habits`.`id`, `habits`.`name`"; !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected %s in sql: %s", want, buf.String())
+	}
+}
+
+func TestApplyFilterComparesTextBinary(t *testing.T) {
+	q := mysql.NewView[habit]("habits", expr.NewColumnsExpr("id", "name")).Query()
+	params := &data.QueryParams{Filter: data.Filter{Conditions: []data.FilterCondition{
+		{Column: "name", Mode: data.Exact, Value: "Gym"},
+		{Column: "name", Mode: data.NotEqual, Value: "gym", IgnoreCase: true},
+		{Column: "id", Mode: data.Exact, Value: "7", Args: []any{int64(7)}},
+	}}}
+
+	var buf bytes.Buffer
+	if _, err := ApplyFilter(q, &params.Filter).WriteQuery(context.Background(), &buf, 1); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	want := "WHERE (BINARY `name` = ?) AND (lower(`name`) <> ?) AND (`id` = ?)"
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected %s in sql: %s", want, buf.String())
+	}
+}
@@ -0,0 +1,303 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+	"github.com/stephenafamo/bob/expr"
+)
+
+// Relation declares a table related to the table of a schema. Its columns
+// are filtered (and, for to-one relations, sorted) with a dotted path, so
+// with a "habit" relation on habit logs and a "group" relation on habits,
+// habit.group.name_ci=fitness filters logs by their habit's group.
+type Relation struct {
+	Name       string  // path segment used in query params, e.g. "habit"
+	Table      string  // related table, e.g. "habits"
+	LocalKey   string  // column of this table, e.g. "habit_id"
+	ForeignKey string  // column of the related table, e.g. "id"
+	Schema     *Schema // columns and relations of the related table
+
+	// Many marks a to-many relation. Conditions on it are matched with an
+	// EXISTS subquery instead of a join, so rows are never duplicated.
+	Many bool
+}
+
+// Join is a LEFT JOIN of a related table under Alias, on Column (of the
+// related table) = Parent (of the table it is joined to).
+type Join struct {
+	Table  string
+	Alias  string
+	Column string
+	Parent string
+}
+
+// ExistsExpr matches when a row of the joined table matching Expr exists.
+// Schema.Resolve builds it for conditions on to-many relations.
+type ExistsExpr struct {
+	Join
+	Expr FilterExpr
+}
+
+func (ExistsExpr) isFilterExpr() {}
+
+func (e ExistsExpr) String() string {
+	return fmt.Sprintf("exists(%s: %v)", e.Alias, e.Expr)
+}
+
+// WithTable sets the table name (or alias) the schema's columns are
+// qualified with. Set it on schemas with relations, so columns that exist
+// in several joined tables are not ambiguous.
+func (s *Schema) WithTable(table string) *Schema {
+	s.table = table
+	return s
+}
+
+// WithRelations declares the relations reachable from the schema.
+func (s *Schema) WithRelations(relations ...Relation) *Schema {
+	if s.relations == nil {
+		s.relations = make(map[string]Relation, len(relations))
+	}
+	for _, r := range relations {
+		if r.Name != "" {
+			s.relations[r.Name] = r
+		}
+	}
+	return s
+}
+
+// columnRef is a declared column, reached through path from the schema it
+// was looked up in.
+type columnRef struct {
+	column Column
+	path   []Relation
+}
+
+func (r columnRef) many() bool {
+	for _, rel := range r.path {
+		if rel.Many {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupRef finds a column by its public name. Names that are not declared
+// columns are followed through relations segment by segment.
+func (s *Schema) lookupRef(name string) (columnRef, bool) {
+	if s == nil {
+		return columnRef{}, false
+	}
+	name = publicName(name)
+	if c, ok := s.columns[name]; ok {
+		return columnRef{column: c}, true
+	}
+	prefix, rest, found := strings.Cut(name, ".")
+	if !found {
+		return columnRef{}, false
+	}
+	rel, ok := s.relations[prefix]
+	if !ok {
+		return columnRef{}, false
+	}
+	ref, ok := rel.Schema.lookupRef(rest)
+	if !ok {
+		return columnRef{}, false
+	}
+	ref.path = append([]Relation{rel}, ref.path...)
+	return ref, true
+}
+
+// alias names the join of the first n relations of path.
+func alias(path []Relation, n int) string {
+	names := make([]string, 0, n)
+	for _, rel := range path[:n] {
+		names = append(names, rel.Name)
+	}
+	return strings.Join(names, "__")
+}
+
+// qualify returns the column of ref qualified with its table: the join
+// alias for related columns, the schema table (if set) otherwise.
+func (s *Schema) qualify(ref columnRef) string {
+	if len(ref.path) > 0 {
+		return alias(ref.path, len(ref.path)) + "." + ref.column.Source
+	}
+	if s.table != "" {
+		return s.table + "." + ref.column.Source
+	}
+	return ref.column.Source
+}
+
+// joins returns the join of every relation along ref's path.
+func (s *Schema) joins(ref columnRef) []Join {
+	joins := make([]Join, 0, len(ref.path))
+	for i, rel := range ref.path {
+		parent := rel.LocalKey
+		if i > 0 {
+			parent = alias(ref.path, i) + "." + rel.LocalKey
+		} else if s.table != "" {
+			parent = s.table + "." + rel.LocalKey
+		}
+		joins = append(joins, Join{
+			Table:  rel.Table,
+			Alias:  alias(ref.path, i+1),
+			Column: alias(ref.path, i+1) + "." + rel.ForeignKey,
+			Parent: parent,
+		})
+	}
+	return joins
+}
+
+// resolveCondition qualifies the column of cond. Conditions on to-many
+// relations are wrapped in one ExistsExpr per relation.
+func (s *Schema) resolveCondition(cond FilterCondition) (FilterExpr, bool) {
+	ref, ok := s.lookupRef(cond.Column)
+	if !ok || !ref.column.Filterable {
+		return nil, false
+	}
+	cond.Column = s.qualify(ref)
+	if !ref.many() {
+		return cond, true
+	}
+	var e FilterExpr = cond
+	joins := s.joins(ref)
+	for i := len(joins) - 1; i >= 0; i-- {
+		e = ExistsExpr{Join: joins[i], Expr: e}
+	}
+	return e, true
+}
+
+// resolveExpr resolves every leaf of e, failing if any is undeclared.
+func (s *Schema) resolveExpr(e FilterExpr) (FilterExpr, error) {
+	resolveAll := func(operands []FilterExpr) ([]FilterExpr, error) {
+		out := make([]FilterExpr, 0, len(operands))
+		for _, operand := range operands {
+			resolved, err := s.resolveExpr(operand)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, resolved)
+		}
+		return out, nil
+	}
+
+	switch e := e.(type) {
+	case FilterCondition:
+		resolved, ok := s.resolveCondition(e)
+		if !ok {
+			return nil, fmt.Errorf("column %q is not filterable", e.Column)
+		}
+		return resolved, nil
+	case AndExpr:
+		out, err := resolveAll(e)
+		return AndExpr(out), err
+	case OrExpr:
+		out, err := resolveAll(e)
+		return OrExpr(out), err
+	case NotExpr:
+		inner, err := s.resolveExpr(e.Expr)
+		return NotExpr{Expr: inner}, err
+	default:
+		return nil, fmt.Errorf("unknown filter expression %T", e)
+	}
+}
+
+// Joins returns the joins the filters, sort keys, groups and aggregates of
+// params need, each relation joined once however many conditions use it.
+// To-many relations are never joined, see Relation.Many.
+func (s *Schema) Joins(params QueryParams) []Join {
+	var (
+		out  []Join
+		seen = map[string]bool{}
+	)
+	add := func(ref columnRef) {
+		if ref.many() {
+			return
+		}
+		for _, j := range s.joins(ref) {
+			if !seen[j.Alias] {
+				seen[j.Alias] = true
+				out = append(out, j)
+			}
+		}
+	}
+
+	for _, cond := range params.Conditions {
+		if ref, ok := s.lookupRef(cond.Column); ok && ref.column.Filterable {
+			add(ref)
+		}
+	}
+	for _, e := range params.Exprs {
+		if _, err := s.resolveExpr(e); err != nil {
+			continue
+		}
+		_, _ = MapConditions(e, func(cond FilterCondition) (FilterCondition, error) {
+			ref, _ := s.lookupRef(cond.Column)
+			add(ref)
+			return cond, nil
+		})
+	}
+	for _, g := range params.GroupBy {
+		if ref, ok := s.lookupRef(g.Column); ok && ref.column.Groupable {
+			add(ref)
+		}
+	}
+	for _, a := range params.Aggregates {
+		if ref, ok := s.lookupRef(a.Column); ok && ref.column.Aggregatable {
+			add(ref)
+		}
+	}
+	if params.Aggregated() {
+		return out
+	}
+	for _, key := range params.Keys {
+		if ref, ok := s.lookupRef(key.Column); ok && ref.column.Sortable {
+			add(ref)
+		}
+	}
+	return out
+}
+
+// quoteRef quotes a table-qualified column such as "habits.name".
+func quoteRef(ref string) psql.Expression {
+	return psql.Quote(strings.Split(ref, ".")...)
+}
+
+// JoinOn returns the ON condition of j.
+func (d Dialect) JoinOn(j Join) bob.Expression {
+	return quoteRef(j.Column).EQ(quoteRef(j.Parent))
+}
+
+// exists renders EXISTS (SELECT 1 FROM table AS alias WHERE on AND expr).
+func (d Dialect) exists(e ExistsExpr) psql.Expression {
+	inner := psql.Raw("TRUE")
+	if e.Expr != nil {
+		inner = d.filterExpr(e.Expr)
+	}
+	return dialect.NewExpression(expr.Join{Exprs: []bob.Expression{
+		psql.Raw("EXISTS (SELECT 1 FROM"),
+		quoteRef(e.Table),
+		psql.Raw("AS"),
+		psql.Quote(e.Alias),
+		psql.Raw("WHERE"),
+		psql.And(d.JoinOn(e.Join), inner),
+		psql.Raw(")"),
+	}})
+}
+
+// ApplyJoins left joins the relations used by the filters and sort keys of
+// params, see Schema.Joins. ApplyAll calls it.
+func ApplyJoins[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams) *psql.ViewQuery[T, S] {
+	if params == nil || params.Schema == nil {
+		return q
+	}
+	for _, j := range params.Schema.Joins(*params) {
+		q.Apply(sm.LeftJoin(quoteRef(j.Table)).As(j.Alias).On(Postgres.JoinOn(j)))
+	}
+	return q
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/expr"
+)
+
+func habitLogSchema() *Schema {
+	groups := NewSchema(Column{Name: "name", Filterable: true, Sortable: true})
+	habits := NewSchema(Column{Name: "name", Filterable: true, Sortable: true}).WithRelations(
+		Relation{Name: "group", Table: "habit_groups", LocalKey: "group_id", ForeignKey: "id", Schema: groups},
+	)
+	tags := NewSchema(Column{Name: "name", Filterable: true, Sortable: true})
+
+	return NewSchema(
+		Column{Name: "name", Filterable: true},
+		Column{Name: "completed_at", Sortable: true},
+	).WithTable("habit_logs").WithRelations(
+		Relation{Name: "habit", Table: "habits", LocalKey: "habit_id", ForeignKey: "id", Schema: habits},
+		Relation{Name: "tags", Table: "habit_log_tags", LocalKey: "id", ForeignKey: "log_id", Schema: tags, Many: true},
+	)
+}
+
+func habitLogsQuery() *psql.ViewQuery[habit, []habit] {
+	return psql.NewView[habit]("", "habit_logs", expr.NewColumnsExpr("id", "name").WithParent("habit_logs")).Query()
+}
+
+func TestApplyAllRelations(t *testing.T) {
+	params := &QueryParams{
+		Pagination: Pagination{Limit: "ALL"},
+		Filter: Filter{
+			Conditions: []FilterCondition{
+				{Column: "habit.group.name", Mode: CaseInsensitive, Value: "fitness"},
+				{Column: "habit.name", Mode: Exact, Value: "Run"},
+				{Column: "tags.name", Mode: Exact, Value: "outdoor"},
+				{Column: "habit.secret", Mode: Exact, Value: "x"},
+			},
+			Exprs: []FilterExpr{OrExpr{
+				FilterCondition{Column: "name", Mode: Exact, Value: "a"},
+				FilterCondition{Column: "habit.group.name", Mode: Exact, Value: "b"},
+			}},
+		},
+		Sort:   Sort{Keys: []SortKey{{Column: "habit.name", Direction: "asc"}, {Column: "tags.name", Direction: "asc"}}},
+		Schema: habitLogSchema(),
+	}
+
+	sql, args := writeQuery(t, ApplyAll(habitLogsQuery(), params))
+
+	for _, want := range []string{
+		`LEFT JOIN "habits" AS "habit" ON ("habit"."id" = "habit_logs"."habit_id")`,
+		`LEFT JOIN "habit_groups" AS "habit__group" ON ("habit__group"."id" = "habit"."group_id")`,
+		`("habit__group"."name" ILIKE $1 ESCAPE '\')`,
+		`("habit"."name" = $2)`,
+		`EXISTS (SELECT 1 FROM "habit_log_tags" AS "tags" WHERE (("tags"."log_id" = "habit_logs"."id") AND ("tags"."name" = $3)) )`,
+		`(("habit_logs"."name" = $4) OR ("habit__group"."name" = $5))`,
+		`ORDER BY "habit"."name" ASC`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	if strings.Count(sql, "JOIN") != 2 {
+		t.Fatalf("expected each relation to be joined once: %s", sql)
+	}
+	if strings.Contains(sql, "secret") || strings.Contains(sql, `"tags"."name" ASC`) {
+		t.Fatalf("undeclared or to-many column reached sql: %s", sql)
+	}
+	if len(args) != 5 {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestSchemaLookupRelations(t *testing.T) {
+	schema := habitLogSchema()
+
+	if column, ok := schema.FilterColumn("habit.group.name"); !ok || column != "habit__group.name" {
+		t.Fatalf("unexpected filter column %q (%v)", column, ok)
+	}
+	if _, ok := schema.SortColumn("tags.name"); ok {
+		t.Fatal("expected to-many columns not to be sortable")
+	}
+	if _, ok := schema.SelectColumn("habit.name"); ok {
+		t.Fatal("expected related columns not to be selectable")
+	}
+	if _, ok := schema.Lookup("habit.group.missing"); ok {
+		t.Fatal("expected undeclared related column to be missing")
+	}
+}
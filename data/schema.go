@@ -0,0 +1,196 @@
+package data
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// identifier matches the column names usable without a schema.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*((\.|->)[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// ValidColumn reports whether name is a plain identifier, optionally
+// qualified with dots or followed by a JSON path (see PathSep), which is
+// what columns referenced without a schema must be. Quoting does not escape
+// double quotes, so other names could break out of the quoted identifier.
+func ValidColumn(name string) bool {
+	return identifier.MatchString(name)
+}
+
+// Column declares a single column of a resource that clients may reference
+// in query params.
+//
+// A column can also be a key inside a JSON column, allowlisting that key:
+// Name "attrs.color" reads the color key of the attrs column. Clients may
+// write it as attrs.color or attrs->color.
+type Column struct {
+	Name       string // public name used in query params
+	Source     string // real column name or JSON path like "attrs->color", defaults to Name
+	Filterable bool
+	Sortable   bool
+	Type       ColumnType // how filter values are parsed, defaults to TypeText
+	Enum       []string   // allowed values for TypeEnum
+
+	// Nullable columns cannot be keyset sort keys, as NULLs fall outside the
+	// cursor comparison. CoerceCursor rejects them.
+	Nullable bool
+
+	// Groupable and Aggregatable allow the column in group_by= and agg=,
+	// see ApplyAggregate.
+	Groupable    bool
+	Aggregatable bool
+}
+
+// Schema declares which columns of a resource can be filtered and sorted on.
+// Columns that are not declared never reach SQL when a Schema is in use.
+type Schema struct {
+	columns   map[string]Column
+	search    *Search
+	table     string
+	relations map[string]Relation
+}
+
+func NewSchema(cols ...Column) *Schema {
+	s := &Schema{columns: make(map[string]Column, len(cols))}
+	for _, c := range cols {
+		if c.Name == "" {
+			continue
+		}
+		if c.Source == "" {
+			c.Source = c.Name
+			if strings.Contains(c.Name, ".") {
+				c.Source = strings.ReplaceAll(c.Name, ".", PathSep)
+			}
+		}
+		s.columns[publicName(c.Name)] = c
+	}
+	return s
+}
+
+// Lookup returns the declared column for a public name, following
+// relations for dotted paths.
+func (s *Schema) Lookup(name string) (Column, bool) {
+	ref, ok := s.lookupRef(name)
+	return ref.column, ok
+}
+
+// publicName spells JSON paths with dots, so attrs->color and attrs.color
+// name the same column.
+func publicName(name string) string {
+	return strings.ReplaceAll(name, PathSep, ".")
+}
+
+// FilterColumn resolves a public name to its real column if it is filterable.
+// Columns are qualified with the table or join alias when there is one.
+func (s *Schema) FilterColumn(name string) (string, bool) {
+	ref, ok := s.lookupRef(name)
+	if !ok || !ref.column.Filterable {
+		return "", false
+	}
+	return s.qualify(ref), true
+}
+
+// SortColumn resolves a public name to its real column if it is sortable.
+// Columns of to-many relations cannot be sorted on.
+func (s *Schema) SortColumn(name string) (string, bool) {
+	ref, ok := s.lookupRef(name)
+	if !ok || !ref.column.Sortable || ref.many() {
+		return "", false
+	}
+	return s.qualify(ref), true
+}
+
+// SelectColumn resolves a public name to its real column. Every declared
+// column of the schema's own table except JSON paths can be selected.
+func (s *Schema) SelectColumn(name string) (string, bool) {
+	ref, ok := s.lookupRef(name)
+	if _, keys := SplitPath(ref.column.Source); !ok || len(ref.path) > 0 || len(keys) > 0 {
+		return "", false
+	}
+	return s.qualify(ref), true
+}
+
+// Resolve returns a copy of params restricted to the columns declared in s,
+// with public names replaced by their real columns. Undeclared filter
+// conditions and sorts are dropped, as are whole filter expressions that
+// reference an undeclared column. Conditions on to-many relations become
+// ExistsExprs. Groups and aggregates keep their public names as aliases,
+// which are then the only valid sort keys. The returned params carry no
+// schema; relations used by the filters and sort keys must be joined, see
+// Joins.
+func (s *Schema) Resolve(params QueryParams) QueryParams {
+	out := params
+	out.Schema = nil
+
+	out.Conditions = nil
+	out.Exprs = nil
+	for _, cond := range params.Conditions {
+		resolved, ok := s.resolveCondition(cond)
+		if !ok {
+			continue
+		}
+		if cond, ok := resolved.(FilterCondition); ok {
+			out.Conditions = append(out.Conditions, cond)
+			continue
+		}
+		out.Exprs = append(out.Exprs, resolved)
+	}
+
+	for _, e := range params.Exprs {
+		resolved, err := s.resolveExpr(e)
+		if err != nil {
+			continue
+		}
+		out.Exprs = append(out.Exprs, resolved)
+	}
+
+	out.Fields = nil
+	for _, field := range params.Fields {
+		if column, ok := s.SelectColumn(field); ok {
+			out.Fields = append(out.Fields, column)
+		}
+	}
+
+	out.GroupBy = nil
+	for _, g := range params.GroupBy {
+		column, ok := s.GroupColumn(g.Column)
+		if !ok || s.CheckGroup(g) != nil {
+			continue
+		}
+		g.As, g.Column = g.Alias(), column
+		out.GroupBy = append(out.GroupBy, g)
+	}
+
+	out.Aggregates = nil
+	for _, a := range params.Aggregates {
+		if s.CheckAggregate(a) != nil {
+			continue
+		}
+		a.As = a.Alias()
+		if a.Column != "" {
+			ref, _ := s.lookupRef(a.Column)
+			a.Column = s.qualify(ref)
+		}
+		out.Aggregates = append(out.Aggregates, a)
+	}
+
+	out.Keys = nil
+	if params.Aggregated() {
+		aliases := out.Aliases()
+		for _, key := range params.Keys {
+			if slices.Contains(aliases, key.Column) {
+				out.Keys = append(out.Keys, key)
+			}
+		}
+		return out
+	}
+	for _, key := range params.Keys {
+		column, ok := s.SortColumn(key.Column)
+		if !ok {
+			continue
+		}
+		key.Column = column
+		out.Keys = append(out.Keys, key)
+	}
+	return out
+}
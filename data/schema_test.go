@@ -0,0 +1,89 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/expr"
+)
+
+type habit struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+func habitsQuery() *psql.ViewQuery[habit, []habit] {
+	return psql.NewView[habit]("", "habits", expr.NewColumnsExpr("id", "name")).Query()
+}
+
+func writeQuery(t *testing.T, q bob.Query) (string, []any) {
+	t.Helper()
+	var buf bytes.Buffer
+	args, err := q.WriteQuery(context.Background(), &buf, 1)
+	if err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	return buf.String(), args
+}
+
+func TestApplyAllSchemaDropsUndeclaredColumns(t *testing.T) {
+	schema := NewSchema(
+		Column{Name: "name", Source: "habit_name", Filterable: true, Sortable: true},
+		Column{Name: "code", Filterable: true},
+	)
+	params := &QueryParams{
+		Pagination: Pagination{Limit: "ALL"},
+		Filter: Filter{Conditions: []FilterCondition{
+			{Column: "name", Mode: Exact, Value: "Focus"},
+			{Column: "password_hash", Mode: Exact, Value: "x"},
+		}},
+		Sort:   Sort{Keys: []SortKey{{Column: "code", Direction: "asc"}}},
+		Schema: schema,
+	}
+
+	sql, args := writeQuery(t, ApplyAll(habitsQuery(), params))
+
+	if !strings.Contains(sql, `WHERE ("habit_name" = $1)`) {
+		t.Fatalf("expected aliased filter, got: %s", sql)
+	}
+	if strings.Contains(sql, "password_hash") {
+		t.Fatalf("undeclared column reached sql: %s", sql)
+	}
+	if strings.Contains(sql, "ORDER BY") {
+		t.Fatalf("non-sortable column reached sql: %s", sql)
+	}
+	if len(args) != 1 || args[0] != "Focus" {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestValidColumn(t *testing.T) {
+	for _, name := range []string{"name", "_id", "habits.name", "attrs->color", "habit.attrs->a->b2"} {
+		if !ValidColumn(name) {
+			t.Errorf("expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"", "1st", `name"`, `x" OR 1=1 --`, "habits.", "a..b", "attrs->", "a b", "a-b"} {
+		if ValidColumn(name) {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestSchemaSortColumn(t *testing.T) {
+	schema := NewSchema(Column{Name: "created", Source: "created_at", Sortable: true})
+
+	if column, ok := schema.SortColumn("created"); !ok || column != "created_at" {
+		t.Fatalf("expected created_at, got %q (%v)", column, ok)
+	}
+	if _, ok := schema.FilterColumn("created"); ok {
+		t.Fatal("expected created not to be filterable")
+	}
+	if _, ok := schema.SortColumn("created_at"); ok {
+		t.Fatal("expected real column name not to be addressable")
+	}
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/stephenafamo/bob/dialect/psql"
+	"github.com/stephenafamo/bob/dialect/psql/dialect"
+	"github.com/stephenafamo/bob/dialect/psql/sm"
+)
+
+// Search configures Postgres full-text search for a resource. Either Column
+// (a stored tsvector) or Expression (SQL the vector is built from with
+// to_tsvector) must be set. Expression is trusted SQL, never client input.
+type Search struct {
+	Column     string // tsvector column, e.g. "search_vector"
+	Expression string // e.g. "coalesce(name, '') || ' ' || coalesce(description, '')"
+	Language   string // text search configuration, defaults to "simple"
+
+	// Rank orders matches by ts_rank, best first, ahead of any other sort.
+	Rank bool
+
+	// Headline adds a ts_headline snippet of this column to the select list
+	// as HeadlineAs (default "snippet"). The row type needs a field for it.
+	Headline        string
+	HeadlineAs      string
+	HeadlineOptions string // e.g. "MaxWords=20, MinWords=5"
+}
+
+var searchLanguage = regexp.MustCompile(`^[a-z_][a-z0-9_.]*$`)
+
+func (s Search) language() psql.Expression {
+	if !searchLanguage.MatchString(s.Language) {
+		return psql.S("simple")
+	}
+	return psql.S(s.Language)
+}
+
+func (s Search) vector() psql.Expression {
+	if s.Column != "" {
+		return psql.Quote(s.Column)
+	}
+	return dialect.NewExpression(psql.F("to_tsvector", s.language(), psql.Raw(s.Expression))())
+}
+
+func (s Search) query(text string) psql.Expression {
+	return dialect.NewExpression(psql.F("websearch_to_tsquery", s.language(), psql.Arg(text))())
+}
+
+func (s Search) enabled(text string) bool {
+	return text != "" && (s.Column != "" || s.Expression != "")
+}
+
+// RanksSearch reports whether a search for text is ordered by rank. Such
+// results cannot be paged by cursor, whose keyset only covers the sort keys.
+func (s *Schema) RanksSearch(text string) bool {
+	return s.search != nil && s.search.Rank && s.search.enabled(text)
+}
+
+func (s Search) match(text string) psql.Expression {
+	return s.vector().OP("@@", s.query(text))
+}
+
+// WithSearch enables full-text search on resources using this schema, see
+// ApplySearch.
+func (s *Schema) WithSearch(cfg Search) *Schema {
+	s.search = &cfg
+	return s
+}
+
+// ApplySearch matches params.Search against the configured tsvector with
+// websearch_to_tsquery, so clients can use quoted phrases, "or" and "-word".
+// It optionally ranks results and adds a highlighted snippet. Apply it before
+// Order so the rank is the primary sort. Keyset pages are not ranked, as the
+// cursor comparison only covers the sort keys.
+func ApplySearch[T any, S ~[]T](q *psql.ViewQuery[T, S], params *QueryParams, cfg Search) *psql.ViewQuery[T, S] {
+	if params == nil || !cfg.enabled(params.Search) {
+		return q
+	}
+
+	q.Apply(sm.Where(cfg.match(params.Search)))
+
+	if cfg.Rank && !params.Keyset() {
+		q.Apply(sm.OrderBy(psql.F("ts_rank", cfg.vector(), cfg.query(params.Search))()).Desc())
+	}
+
+	if cfg.Headline != "" {
+		alias := cfg.HeadlineAs
+		if alias == "" {
+			alias = "snippet"
+		}
+		args := []any{cfg.language(), psql.Quote(cfg.Headline), cfg.query(params.Search)}
+		if cfg.HeadlineOptions != "" {
+			args = append(args, psql.Arg(cfg.HeadlineOptions))
+		}
+		headline := dialect.NewExpression(psql.F("ts_headline", args...)()).As(alias)
+		// Appended at build time, after the view has filled in its default
+		// columns, so the snippet is selected in addition to them.
+		q.Expression.AppendContextualModFunc(func(ctx context.Context, q *dialect.SelectQuery) (context.Context, error) {
+			q.AppendSelect(headline)
+			return ctx, nil
+		})
+	}
+	return q
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplySearch(t *testing.T) {
+	params := &QueryParams{Search: `"morning run" -gym`}
+	cfg := Search{
+		Column:     "search_vector",
+		Language:   "english",
+		Rank:       true,
+		Headline:   "description",
+		HeadlineAs: "snippet",
+	}
+
+	sql, args := writeQuery(t, ApplySearch(habitsQuery(), params, cfg))
+
+	for _, want := range []string{
+		`"id" AS "id", "name" AS "name", ts_headline('english', "description", websearch_to_tsquery('english', $1)) AS "snippet"`,
+		`WHERE ("search_vector" @@ websearch_to_tsquery('english', $2))`,
+		`ORDER BY ts_rank("search_vector", websearch_to_tsquery('english', $3)) DESC`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	if len(args) != 3 || args[0] != params.Search {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestApplySearchExpressionAndLanguage(t *testing.T) {
+	params := &QueryParams{Search: "gym"}
+	cfg := Search{Expression: "coalesce(name, '')", Language: "english'; drop table habits; --"}
+
+	sql, _ := writeQuery(t, ApplySearch(habitsQuery(), params, cfg))
+	if !strings.Contains(sql, `(to_tsvector('simple', coalesce(name, '')) @@ websearch_to_tsquery('simple', $1))`) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestApplyAllKeysetSkipsRank(t *testing.T) {
+	schema := NewSchema(Column{Name: "id", Sortable: true}).WithSearch(Search{Column: "search_vector", Rank: true})
+	params := &QueryParams{
+		Search:     "gym",
+		Schema:     schema,
+		Pagination: Pagination{Limit: int64(10), After: &Cursor{Values: []any{"7"}}},
+		Sort:       Sort{Keys: []SortKey{{Column: "id", Direction: "asc"}}},
+	}
+	if !schema.RanksSearch(params.Search) || schema.RanksSearch("") {
+		t.Fatal("expected RanksSearch only for a search text")
+	}
+
+	sql, _ := writeQuery(t, ApplyAll(habitsQuery(), params))
+	if strings.Contains(sql, "ts_rank") || !strings.Contains(sql, `ORDER BY "id" ASC`) {
+		t.Fatalf("expected keyset order without rank: %s", sql)
+	}
+}
@@ -0,0 +1,656 @@
+package data
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnmappedColumn is returned by ApplyToSlice for columns that match
+	// neither an accessor nor a struct field.
+	ErrUnmappedColumn = errors.New("data: column is not mapped to a field")
+
+	// ErrKeysetSlice is returned by ApplyToSlice for params carrying a cursor.
+	ErrKeysetSlice = errors.New("data: ApplyToSlice does not support keyset pagination")
+)
+
+type SliceOption[T any] func(*sliceConfig[T])
+
+type sliceConfig[T any] struct {
+	accessors map[string]func(T) any
+}
+
+// WithAccessor reads column from an item with fn instead of a struct field.
+// column is the real column name, as in Column.Source.
+func WithAccessor[T any](column string, fn func(T) any) SliceOption[T] {
+	return func(c *sliceConfig[T]) {
+		c.accessors[column] = fn
+	}
+}
+
+// ApplyToSlice filters, sorts and pages items in memory the way ApplyAll
+// does in Postgres, for resources served from caches or other APIs. Columns
+// map to struct fields by their db tag, then their json tag, unless an
+// accessor is registered with WithAccessor. JSON paths walk map fields.
+//
+// Conditions follow SQL semantics: pattern modes are LIKE matches, NULL
+// (nil pointers, invalid sql.Null* values) never matches a comparison, and
+// NULLs sort last ascending and first descending unless the sort key says
+// otherwise. Raw string values are parsed to the field
+// type; values that cannot be compared with a field match like NULL.
+// Strings compare byte by byte. Search and Fields are not applied.
+func ApplyToSlice[T any](items []T, params *QueryParams, opts ...SliceOption[T]) (SliceResult[T], error) {
+	if params == nil {
+		return Slice(items, int64(len(items))), nil
+	}
+	if params.Keyset() {
+		return SliceResult[T]{}, ErrKeysetSlice
+	}
+
+	e := evaluator[T]{
+		cfg:    sliceConfig[T]{accessors: map[string]func(T) any{}},
+		schema: params.Schema,
+		fields: structFields(reflect.TypeFor[T]()),
+	}
+	for _, opt := range opts {
+		opt(&e.cfg)
+	}
+
+	where, err := e.where(&params.Filter)
+	if err != nil {
+		return SliceResult[T]{}, err
+	}
+	order, err := e.order(&params.Sort)
+	if err != nil {
+		return SliceResult[T]{}, err
+	}
+
+	matched := make([]T, 0, len(items))
+	for _, item := range items {
+		if where(item) == sqlTrue {
+			matched = append(matched, item)
+		}
+	}
+	slices.SortStableFunc(matched, order)
+
+	return Slice(pageOf(matched, &params.Pagination), int64(len(matched))).Paged(params), nil
+}
+
+func pageOf[T any](items []T, pg *Pagination) []T {
+	if pg.Offset > 0 {
+		items = items[min(pg.Offset, int64(len(items))):]
+	}
+	if limit, ok := pg.PageSize(); ok && limit >= 0 && limit < int64(len(items)) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// truth is a value of SQL's three-valued logic.
+type truth int8
+
+const (
+	sqlFalse truth = iota
+	sqlTrue
+	sqlNull
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return sqlTrue
+	}
+	return sqlFalse
+}
+
+type predicate[T any] func(T) truth
+
+type evaluator[T any] struct {
+	cfg    sliceConfig[T]
+	schema *Schema
+	fields map[string][]int
+}
+
+// structFields maps db tags (or json tags) of T's fields to their index.
+func structFields(t reflect.Type) map[string][]int {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	fields := map[string][]int{}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		for _, tag := range []string{"db", "json"} {
+			name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			if _, ok := fields[name]; !ok {
+				fields[name] = f.Index
+			}
+			break
+		}
+	}
+	return fields
+}
+
+// getter returns the accessor for a column, resolving its public name with
+// the schema. ok is false for columns the schema does not allow, which are
+// dropped the way Schema.Resolve drops them.
+func (e *evaluator[T]) getter(name string, sort bool) (get func(T) any, ok bool, err error) {
+	source := name
+	if e.schema != nil {
+		ref, found := e.schema.lookupRef(name)
+		switch {
+		case !found, sort && (!ref.column.Sortable || ref.many()), !sort && !ref.column.Filterable:
+			return nil, false, nil
+		case len(ref.path) > 0:
+			return nil, false, fmt.Errorf("%w: %q is a column of relation %q", ErrUnmappedColumn, name, ref.path[0].Name)
+		}
+		source = ref.column.Source
+	}
+
+	column, keys := SplitPath(source)
+	get, ok = e.cfg.accessors[column]
+	if !ok {
+		index, found := e.fields[column]
+		if !found {
+			return nil, false, fmt.Errorf("%w: %q", ErrUnmappedColumn, column)
+		}
+		get = func(item T) any {
+			v := reflect.ValueOf(item)
+			for v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return nil
+				}
+				v = v.Elem()
+			}
+			f, err := v.FieldByIndexErr(index)
+			if err != nil {
+				return nil
+			}
+			return f.Interface()
+		}
+	}
+	if len(keys) == 0 {
+		return get, true, nil
+	}
+	base := get
+	return func(item T) any { return jsonKeys(base(item), keys) }, true, nil
+}
+
+// jsonKeys walks keys through maps, decoding JSON documents on the way.
+// Missing keys yield nil, like SQL.
+func jsonKeys(v any, keys []string) any {
+	for _, key := range keys {
+		if raw, ok := v.(json.RawMessage); ok {
+			v = []byte(raw)
+		}
+		if raw, ok := v.([]byte); ok {
+			var doc any
+			if json.Unmarshal(raw, &doc) != nil {
+				return nil
+			}
+			v = doc
+		}
+		m := reflect.ValueOf(v)
+		for m.Kind() == reflect.Pointer || m.Kind() == reflect.Interface {
+			if m.IsNil() {
+				return nil
+			}
+			m = m.Elem()
+		}
+		if m.Kind() != reflect.Map || m.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		value := m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key()))
+		if !value.IsValid() {
+			return nil
+		}
+		v = value.Interface()
+	}
+	return v
+}
+
+// where compiles the filter into a predicate ANDing all of its parts.
+func (e *evaluator[T]) where(f *Filter) (predicate[T], error) {
+	var parts []predicate[T]
+	for _, cond := range f.Conditions {
+		p, ok, err := e.condition(cond)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			parts = append(parts, p)
+		}
+	}
+	for _, expr := range f.Exprs {
+		p, ok, err := e.expr(expr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			parts = append(parts, p)
+		}
+	}
+	return all(parts), nil
+}
+
+// expr compiles a filter expression. ok is false when the schema drops it,
+// see Schema.Resolve.
+func (e *evaluator[T]) expr(expr FilterExpr) (predicate[T], bool, error) {
+	operands := func(exprs []FilterExpr) ([]predicate[T], bool, error) {
+		out := make([]predicate[T], 0, len(exprs))
+		for _, operand := range exprs {
+			p, ok, err := e.expr(operand)
+			if err != nil || !ok {
+				return nil, ok, err
+			}
+			out = append(out, p)
+		}
+		return out, true, nil
+	}
+
+	switch expr := expr.(type) {
+	case FilterCondition:
+		return e.condition(expr)
+	case AndExpr:
+		if len(expr) == 0 {
+			return func(T) truth { return sqlTrue }, true, nil
+		}
+		parts, ok, err := operands(expr)
+		return all(parts), ok, err
+	case OrExpr:
+		if len(expr) == 0 {
+			return func(T) truth { return sqlFalse }, true, nil
+		}
+		parts, ok, err := operands(expr)
+		return anyOf(parts), ok, err
+	case NotExpr:
+		if expr.Expr == nil {
+			return func(T) truth { return sqlFalse }, true, nil
+		}
+		inner, ok, err := e.expr(expr.Expr)
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+		return func(item T) truth {
+			switch inner(item) {
+			case sqlTrue:
+				return sqlFalse
+			case sqlFalse:
+				return sqlTrue
+			default:
+				return sqlNull
+			}
+		}, true, nil
+	default:
+		return nil, false, fmt.Errorf("data: filter expression %T cannot be evaluated in memory", expr)
+	}
+}
+
+func all[T any](parts []predicate[T]) predicate[T] {
+	return func(item T) truth {
+		result := sqlTrue
+		for _, p := range parts {
+			switch p(item) {
+			case sqlFalse:
+				return sqlFalse
+			case sqlNull:
+				result = sqlNull
+			}
+		}
+		return result
+	}
+}
+
+func anyOf[T any](parts []predicate[T]) predicate[T] {
+	return func(item T) truth {
+		result := sqlFalse
+		for _, p := range parts {
+			switch p(item) {
+			case sqlTrue:
+				return sqlTrue
+			case sqlNull:
+				result = sqlNull
+			}
+		}
+		return result
+	}
+}
+
+// condition compiles a single condition, mirroring Dialect.condition:
+// malformed list conditions are false.
+func (e *evaluator[T]) condition(cond FilterCondition) (predicate[T], bool, error) {
+	get, ok, err := e.getter(cond.Column, false)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	args := slices.Clone(cond.args())
+	for i := range args {
+		args[i] = sqlValue(args[i])
+	}
+	never := func(T) truth { return sqlFalse }
+
+	// value reads the field, lowered for case-insensitive comparisons of
+	// text like foldCase does in SQL.
+	fold := cond.IgnoreCase && !cond.Mode.Pattern() && allText(args)
+	if fold {
+		for i := range args {
+			args[i] = strings.ToLower(args[i].(string))
+		}
+	}
+	value := func(item T) any {
+		v := sqlValue(get(item))
+		if text, ok := v.(string); ok && fold {
+			return strings.ToLower(text)
+		}
+		return v
+	}
+
+	// compare evaluates the field against an argument; the field being
+	// NULL or not comparable with the argument gives NULL.
+	compare := func(item T, arg any, match func(int) bool) truth {
+		c, ok := compareValues(value(item), arg)
+		if !ok {
+			return sqlNull
+		}
+		return truthOf(match(c))
+	}
+
+	switch cond.Mode {
+	case IsNull, NotNull:
+		return func(item T) truth {
+			return truthOf((sqlValue(get(item)) == nil) == (cond.Mode == IsNull))
+		}, true, nil
+	case In, NotIn:
+		if len(args) == 0 {
+			return never, true, nil
+		}
+		return func(item T) truth {
+			result := sqlFalse
+			for _, arg := range args {
+				switch compare(item, arg, func(c int) bool { return c == 0 }) {
+				case sqlTrue:
+					result = sqlTrue
+				case sqlNull:
+					if result == sqlFalse {
+						result = sqlNull
+					}
+				}
+			}
+			if cond.Mode == NotIn && result != sqlNull {
+				result = truthOf(result == sqlFalse)
+			}
+			return result
+		}, true, nil
+	case Between, Within:
+		if len(args) != 2 {
+			return never, true, nil
+		}
+		upper := func(c int) bool { return c <= 0 }
+		if cond.Mode == Within {
+			upper = func(c int) bool { return c < 0 }
+		}
+		return all([]predicate[T]{
+			func(item T) truth { return compare(item, args[0], func(c int) bool { return c >= 0 }) },
+			func(item T) truth { return compare(item, args[1], upper) },
+		}), true, nil
+	}
+
+	if len(args) != 1 {
+		return never, true, nil
+	}
+	var match func(int) bool
+	switch cond.Mode {
+	case Exact:
+		match = func(c int) bool { return c == 0 }
+	case NotEqual:
+		match = func(c int) bool { return c != 0 }
+	case GreaterThan:
+		match = func(c int) bool { return c > 0 }
+	case GreaterOrEqual:
+		match = func(c int) bool { return c >= 0 }
+	case LessThan:
+		match = func(c int) bool { return c < 0 }
+	case LessOrEqual:
+		match = func(c int) bool { return c <= 0 }
+	default:
+		like := likeRegexp(cond.pattern(), cond.ignoresCase())
+		return func(item T) truth {
+			text, ok := sqlValue(get(item)).(string)
+			if !ok {
+				return sqlNull
+			}
+			return truthOf(like.MatchString(text))
+		}, true, nil
+	}
+	return func(item T) truth { return compare(item, args[0], match) }, true, nil
+}
+
+// order compiles the sort keys into a comparison, mirroring Dialect.OrderBy
+// on Postgres: keys with an unknown direction are skipped.
+func (e *evaluator[T]) order(s *Sort) (func(a, b T) int, error) {
+	type key struct {
+		get        func(T) any
+		desc       bool
+		nullsFirst bool
+	}
+	keys := make([]key, 0, len(s.Keys))
+	for _, k := range s.Keys {
+		if k.Direction != "asc" && k.Direction != "desc" {
+			continue
+		}
+		get, ok, err := e.getter(k.Column, true)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		desc := k.Direction == "desc"
+		nullsFirst := desc
+		switch k.Nulls {
+		case "first":
+			nullsFirst = true
+		case "last":
+			nullsFirst = false
+		}
+		keys = append(keys, key{get: get, desc: desc, nullsFirst: nullsFirst})
+	}
+
+	return func(a, b T) int {
+		for _, k := range keys {
+			va, vb := sqlValue(k.get(a)), sqlValue(k.get(b))
+			if va == nil || vb == nil {
+				if va == nil && vb == nil {
+					continue
+				}
+				if (va == nil) == k.nullsFirst {
+					return -1
+				}
+				return 1
+			}
+			c, _ := compareValues(va, vb)
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}, nil
+}
+
+// sqlValue reduces v to the value a database driver would see: nil for
+// NULL, int64, float64, bool, string or time.Time.
+func sqlValue(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	v = rv.Interface()
+
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || value == nil {
+			return nil
+		}
+		v, rv = value, reflect.ValueOf(value)
+	}
+	if _, ok := v.(time.Time); ok {
+		return v
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes())
+		}
+	}
+	return v
+}
+
+// compareValues compares a field value with an argument. Raw string
+// arguments are parsed to the field type, as the database casts untyped
+// parameters. ok is false when the values are not comparable.
+func compareValues(a, b any) (int, bool) {
+	if raw, isString := b.(string); isString {
+		if _, isString := a.(string); !isString {
+			parsed, ok := parseAs(a, raw)
+			if !ok {
+				return 0, false
+			}
+			b = parsed
+		}
+	}
+
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return cmp.Compare(a, b), true
+		case float64:
+			return cmp.Compare(float64(a), b), true
+		}
+	case float64:
+		switch b := b.(type) {
+		case int64:
+			return cmp.Compare(a, float64(b)), true
+		case float64:
+			return cmp.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return cmp.Compare(boolInt(a), boolInt(b)), true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b), true
+		}
+	}
+	return 0, false
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parseAs parses raw to the type of like.
+func parseAs(like any, raw string) (any, bool) {
+	switch like.(type) {
+	case int64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		return v, err == nil
+	case float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		return v, err == nil
+	case bool:
+		v, err := strconv.ParseBool(raw)
+		return v, err == nil
+	case time.Time:
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if v, err := time.Parse(layout, raw); err == nil {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func allText(args []any) bool {
+	for _, arg := range args {
+		if _, ok := arg.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// likeRegexp translates a LIKE pattern into a regular expression: % matches
+// any run of characters, _ a single character and a backslash escapes the
+// character after it. fold ignores case, as ILIKE does.
+func likeRegexp(pattern string, fold bool) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	if fold {
+		b.WriteString(`(?i)`)
+	}
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(`.*`)
+		case r == '_':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		b.WriteString(`\\`)
+	}
+	b.WriteString(`$`)
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,143 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type cachedHabit struct {
+	ID      int64          `db:"id"`
+	Name    string         `json:"name"`
+	Score   *int           `db:"score"`
+	Created time.Time      `db:"created_at"`
+	Attrs   map[string]any `db:"attrs"`
+}
+
+func cachedHabits() []cachedHabit {
+	score := func(n int) *int { return &n }
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 12, 0, 0, 0, time.UTC) }
+	return []cachedHabit{
+		{ID: 1, Name: "Morning Run", Score: score(5), Created: day(1), Attrs: map[string]any{"color": "red"}},
+		{ID: 2, Name: "Read", Score: nil, Created: day(2)},
+		{ID: 3, Name: "Evening run", Score: score(3), Created: day(3), Attrs: map[string]any{"color": "blue"}},
+		{ID: 4, Name: "Gym", Score: score(8), Created: day(4), Attrs: map[string]any{"color": "red"}},
+	}
+}
+
+func ids(items []cachedHabit) []int64 {
+	out := make([]int64, 0, len(items))
+	for _, item := range items {
+		out = append(out, item.ID)
+	}
+	return out
+}
+
+func TestApplyToSliceFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64
+	}{
+		{"contains", Filter{Conditions: []FilterCondition{{Column: "name", Mode: Anywhere, Value: "run"}}}, []int64{3}},
+		{"contains ignoring case", Filter{Conditions: []FilterCondition{{Column: "name", Mode: Anywhere, Value: "RUN", IgnoreCase: true}}}, []int64{1, 3}},
+		{"starts with", Filter{Conditions: []FilterCondition{{Column: "name", Mode: Start, Value: "Morning"}}}, []int64{1}},
+		{"ends with", Filter{Conditions: []FilterCondition{{Column: "name", Mode: End, Value: "Run"}}}, []int64{1}},
+		{"wildcards escaped", Filter{Conditions: []FilterCondition{{Column: "name", Mode: CaseInsensitive, Value: "_ym"}}}, []int64{}},
+		{"in ignoring case", Filter{Conditions: []FilterCondition{{Column: "name", Mode: In, Value: "read,GYM", IgnoreCase: true}}}, []int64{2, 4}},
+		{"raw values parsed", Filter{Conditions: []FilterCondition{{Column: "score", Mode: GreaterThan, Value: "4"}}}, []int64{1, 4}},
+		{"null never compares", Filter{Conditions: []FilterCondition{{Column: "score", Mode: NotEqual, Value: "5"}}}, []int64{3, 4}},
+		{"is null", Filter{Conditions: []FilterCondition{{Column: "score", Mode: IsNull}}}, []int64{2}},
+		{"not in", Filter{Conditions: []FilterCondition{{Column: "id", Mode: NotIn, Value: "1,2"}}}, []int64{3, 4}},
+		{"within", Filter{Conditions: []FilterCondition{{Column: "created_at", Mode: Within, Value: "2024-05-02T12:00:00Z,2024-05-04T12:00:00Z"}}}, []int64{2, 3}},
+		{"malformed between", Filter{Conditions: []FilterCondition{{Column: "id", Mode: Between, Value: "1"}}}, []int64{}},
+		{"json path", Filter{Conditions: []FilterCondition{{Column: "attrs->color", Mode: Exact, Value: "red"}}}, []int64{1, 4}},
+		{"not null operand", Filter{Exprs: []FilterExpr{NotExpr{Expr: FilterCondition{Column: "score", Mode: LessThan, Value: "4"}}}}, []int64{1, 4}},
+		{"or", Filter{Exprs: []FilterExpr{OrExpr{
+			FilterCondition{Column: "id", Mode: Exact, Value: "2"},
+			FilterCondition{Column: "score", Mode: GreaterOrEqual, Value: "8"},
+		}}}, []int64{2, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ApplyToSlice(cachedHabits(), &QueryParams{Filter: tt.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(res.Data); !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if res.Total != int64(len(tt.want)) {
+				t.Fatalf("unexpected total %d", res.Total)
+			}
+		})
+	}
+}
+
+func TestApplyToSliceSortAndPage(t *testing.T) {
+	items := cachedHabits()
+	params := &QueryParams{
+		Pagination: Pagination{Limit: 2, Offset: 1},
+		Sort:       Sort{Keys: []SortKey{{Column: "score", Direction: "desc"}, {Column: "name", Direction: "asc"}}},
+	}
+	res, err := ApplyToSlice(items, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// desc puts NULLs first, like Postgres: 2, 4, 1, 3
+	if got := ids(res.Data); !slices.Equal(got, []int64{4, 1}) {
+		t.Fatalf("unexpected page %v", got)
+	}
+	if res.Total != 4 || res.Page != 1 || res.PageCount != 2 {
+		t.Fatalf("unexpected paging %+v", res)
+	}
+	if ids(items)[0] != 1 {
+		t.Fatal("input slice was reordered")
+	}
+
+	params.Keys[0].Nulls = "last"
+	params.Pagination = Pagination{Limit: "ALL"}
+	res, _ = ApplyToSlice(items, params)
+	if got := ids(res.Data); !slices.Equal(got, []int64{4, 1, 3, 2}) {
+		t.Fatalf("unexpected order with nulls last %v", got)
+	}
+}
+
+func TestApplyToSliceSchema(t *testing.T) {
+	schema := NewSchema(
+		Column{Name: "title", Source: "name", Filterable: true, Sortable: true},
+		Column{Name: "score", Filterable: true, Type: TypeInt},
+		Column{Name: "label", Filterable: true},
+	)
+	params := &QueryParams{
+		Filter: Filter{Conditions: []FilterCondition{
+			{Column: "title", Mode: Anywhere, Value: "run", IgnoreCase: true},
+			{Column: "id", Mode: Exact, Value: "1"},
+			{Column: "label", Mode: Exact, Value: "EVENING RUN"},
+		}},
+		Sort:   Sort{Keys: []SortKey{{Column: "score", Direction: "asc"}, {Column: "title", Direction: "desc"}}},
+		Schema: schema,
+	}
+	upper := WithAccessor("label", func(h cachedHabit) any { return strings.ToUpper(h.Name) })
+
+	res, err := ApplyToSlice(cachedHabits(), params, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(res.Data); !slices.Equal(got, []int64{3}) {
+		t.Fatalf("unexpected result %v", got)
+	}
+}
+
+func TestApplyToSliceErrors(t *testing.T) {
+	_, err := ApplyToSlice(cachedHabits(), &QueryParams{Filter: Filter{Conditions: []FilterCondition{{Column: "missing", Mode: Exact, Value: "x"}}}})
+	if !errors.Is(err, ErrUnmappedColumn) {
+		t.Fatalf("expected ErrUnmappedColumn, got %v", err)
+	}
+	_, err = ApplyToSlice(cachedHabits(), &QueryParams{Pagination: Pagination{After: &Cursor{}}})
+	if !errors.Is(err, ErrKeysetSlice) {
+		t.Fatalf("expected ErrKeysetSlice, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package sqlite
+
+import (
+	"github.com/stephenafamo/bob"
+	"github.com/stephenafamo/bob/dialect/sqlite/dialect"
+	"github.com/tschuyebuhl/httpkit/data"
+	"github.com/tschuyebuhl/httpkit/data/internal/bobquery"
+)
+
+// DeleteWhere returns a mod applying f to a bob DELETE query, see
+// data.DeleteWhere.
+func DeleteWhere(f *data.Filter, opts ...data.MutationOption) (bob.Mod[*dialect.DeleteQuery], error) {
+	return bobquery.MutationWhere[*dialect.DeleteQuery](data.SQLite, f, opts...)
+}
+
+// UpdateWhere returns a mod applying f to a bob UPDATE query, see
+// data.UpdateWhere.
+func UpdateWhere(f *data.Filter, opts ...data.MutationOption) (bob.Mod[*dialect.UpdateQuery], error) {
+	return bobquery.MutationWhere[*dialect.UpdateQuery](data.SQLite, f, opts...)
+}
@@ -0,0 +1,47 @@
+// Package sqlite applies data.QueryParams to bob SQLite queries. It mirrors the
+// Postgres helpers in package data.
+package sqlite
+
+import (
+	"github.com/stephenafamo/bob/dialect/sqlite"
+	"github.com/tschuyebuhl/httpkit/data"
+	"github.com/tschuyebuhl/httpkit/data/internal/bobquery"
+)
+
+// Page applies LIMIT and OFFSET. SQLite has no LIMIT ALL, so "ALL" omits the
+// LIMIT, or uses LIMIT -1 when there is an offset.
+func Page[T any, S ~[]T](q *sqlite.ViewQuery[T, S], pg *data.Pagination) *sqlite.ViewQuery[T, S] {
+	bobquery.Page(data.SQLite, q.Expression, pg)
+	return q
+}
+
+// Order applies every sort key as its own ORDER BY term.
+func Order[T any, S ~[]T](q *sqlite.ViewQuery[T, S], s *data.Sort) *sqlite.ViewQuery[T, S] {
+	bobquery.Order(data.SQLite, q.Expression, s)
+	return q
+}
+
+// ApplyFilter applies the filter conditions and expressions. Pattern modes
+// use LIKE, which is case-insensitive for ASCII characters.
+func ApplyFilter[T any, S ~[]T](q *sqlite.ViewQuery[T, S], f *data.Filter) *sqlite.ViewQuery[T, S] {
+	bobquery.Filter(data.SQLite, q.Expression, f)
+	return q
+}
+
+// PageByCursor applies keyset pagination, see data.PageByCursor.
+func PageByCursor[T any, S ~[]T](q *sqlite.ViewQuery[T, S], params *data.QueryParams) *sqlite.ViewQuery[T, S] {
+	bobquery.PageByCursor(data.SQLite, q.Expression, params)
+	return q
+}
+
+// SelectFields limits the selected columns to params.Fields, see
+// data.SelectFields.
+func SelectFields[T any, S ~[]T](q *sqlite.ViewQuery[T, S], params *data.QueryParams) *sqlite.ViewQuery[T, S] {
+	bobquery.SelectFields(q.Expression, params)
+	return q
+}
+
+func ApplyAll[T any, S ~[]T](q *sqlite.ViewQuery[T, S], params *data.QueryParams) *sqlite.ViewQuery[T, S] {
+	bobquery.All(data.SQLite, q.Expression, params)
+	return q
+}
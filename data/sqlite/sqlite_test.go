@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stephenafamo/bob/dialect/sqlite"
+	"github.com/stephenafamo/bob/expr"
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+type habit struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestApplyAll(t *testing.T) {
+	q := sqlite.NewView[habit]("", "habits", expr.NewColumnsExpr("id", "name")).Query()
+	params := &data.QueryParams{
+		Pagination: data.Pagination{Limit: "ALL", Offset: 40},
+		Filter: data.Filter{Conditions: []data.FilterCondition{
+			{Column: "name", Mode: data.CaseInsensitive, Value: "Gym"},
+			{Column: "name", Mode: data.End, Value: "[x]*"},
+		}},
+		Sort: data.Sort{Keys: []data.SortKey{{Column: "completed_at", Direction: "desc", Nulls: "last"}}},
+	}
+
+	var buf bytes.Buffer
+	args, err := ApplyAll(q, params).WriteQuery(context.Background(), &buf, 1)
+	if err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+
+	sql := buf.String()
+	for _, want := range []string{
+		`WHERE ("name" LIKE ?1 ESCAPE '\') AND ("name" GLOB ?2)`,
+		`ORDER BY "completed_at" DESC NULLS LAST`,
+		"LIMIT -1",
+		"OFFSET 40",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %s in sql: %s", want, sql)
+		}
+	}
+	if len(args) != 2 || args[0] != "Gym" || args[1] != "*[[]x][*]" {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestSelectFieldsWithTable(t *testing.T) {
+	q := sqlite.NewView[habit]("", "habits", expr.NewColumnsExpr("id", "name")).Query()
+	params := &data.QueryParams{
+		Fields: []string{"id", "title"},
+		Schema: data.NewSchema(
+			data.Column{Name: "id"},
+			data.Column{Name: "title", Source: "name"},
+		).WithTable("habits"),
+	}
+
+	var buf bytes.Buffer
+	if _, err := SelectFields(q, params).WriteQuery(context.Background(), &buf, 1); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	if want := `"habits"."id", "habits"."name"`; !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected %s in sql: %s", want, buf.String())
+	}
+}
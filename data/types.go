@@ -0,0 +1,139 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// ColumnType declares how filter values for a column are parsed before they
+// are bound to a query.
+type ColumnType int
+
+const (
+	TypeText  ColumnType = iota // TypeText = 0, values are bound as strings
+	TypeInt                     // TypeInt = 1, int64
+	TypeFloat                   // TypeFloat = 2, float64
+	TypeBool                    // TypeBool = 3, bool
+	TypeUUID                    // TypeUUID = 4, uuid.UUID
+	TypeTime                    // TypeTime = 5, RFC 3339 time.Time
+	TypeDate                    // TypeDate = 6, YYYY-MM-DD time.Time
+	TypeEnum                    // TypeEnum = 7, string from Column.Enum
+)
+
+var ErrInvalidValue = errors.New("invalid filter value")
+
+// ParseValue parses a single raw filter value according to the column type.
+func (c Column) ParseValue(raw string) (any, error) {
+	switch c.Type {
+	case TypeText:
+		return raw, nil
+	case TypeInt:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not an integer", ErrInvalidValue, raw)
+		}
+		return v, nil
+	case TypeFloat:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidValue, raw)
+		}
+		return v, nil
+	case TypeBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a boolean", ErrInvalidValue, raw)
+		}
+		return v, nil
+	case TypeUUID:
+		v, err := uuid.FromString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a uuid", ErrInvalidValue, raw)
+		}
+		return v, nil
+	case TypeTime:
+		v, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not an RFC 3339 timestamp", ErrInvalidValue, raw)
+		}
+		return v, nil
+	case TypeDate:
+		v, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a YYYY-MM-DD date", ErrInvalidValue, raw)
+		}
+		return v, nil
+	case TypeEnum:
+		if !slices.Contains(c.Enum, raw) {
+			return nil, fmt.Errorf("%w: %q is not one of %s", ErrInvalidValue, raw, strings.Join(c.Enum, ", "))
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown column type %d", ErrInvalidValue, c.Type)
+	}
+}
+
+// FormatValue writes v the way ParseValue reads it for the column type:
+// times as YYYY-MM-DD for TypeDate and RFC 3339 otherwise, anything else
+// with fmt.Sprint.
+func (c Column) FormatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		if c.Type == TypeDate {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Coerce parses the condition value(s) with the column type declared for
+// cond.Column and stores them in cond.Args. Pattern modes (CaseInsensitive,
+// Start, End, Anywhere) and IgnoreCase are only allowed on text and enum
+// columns, Within only on time and date columns.
+func (s *Schema) Coerce(cond FilterCondition) (FilterCondition, error) {
+	c, ok := s.Lookup(cond.Column)
+	if !ok {
+		return cond, fmt.Errorf("%w: unknown column %q", ErrInvalidValue, cond.Column)
+	}
+	if cond.IgnoreCase && c.Type != TypeText && c.Type != TypeEnum {
+		return cond, fmt.Errorf("%w: column %q cannot be matched ignoring case", ErrInvalidValue, cond.Column)
+	}
+	switch cond.Mode {
+	case IsNull, NotNull:
+		return cond, nil
+	case CaseInsensitive, Start, End, Anywhere:
+		if c.Type != TypeText && c.Type != TypeEnum {
+			return cond, fmt.Errorf("%w: mode %s is not supported on column %q", ErrInvalidValue, cond.Mode, cond.Column)
+		}
+		return cond, nil
+	case Within:
+		if c.Type != TypeTime && c.Type != TypeDate {
+			return cond, fmt.Errorf("%w: mode %s is not supported on column %q", ErrInvalidValue, cond.Mode, cond.Column)
+		}
+	}
+	if c.Type == TypeText {
+		return cond, nil
+	}
+
+	values := cond.Values()
+	args := make([]any, 0, len(values))
+	for _, raw := range values {
+		v, err := c.ParseValue(raw)
+		if err != nil {
+			return cond, err
+		}
+		args = append(args, v)
+	}
+	cond.Args = args
+	return cond, nil
+}
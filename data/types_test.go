@@ -0,0 +1,41 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+func TestSchemaCoerce(t *testing.T) {
+	schema := NewSchema(
+		Column{Name: "id", Filterable: true, Type: TypeUUID},
+		Column{Name: "score", Filterable: true, Type: TypeInt},
+	)
+
+	cond, err := schema.Coerce(FilterCondition{Column: "id", Mode: Exact, Value: "f86b053f-94ce-4c6f-b13a-e1208979218a"})
+	if err != nil {
+		t.Fatalf("coerce uuid: %v", err)
+	}
+	if _, ok := cond.Args[0].(uuid.UUID); !ok {
+		t.Fatalf("expected uuid arg, got %#v", cond.Args)
+	}
+
+	if _, err := schema.Coerce(FilterCondition{Column: "id", Mode: Exact, Value: "nope"}); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+	if _, err := schema.Coerce(FilterCondition{Column: "score", Mode: Anywhere, Value: "1"}); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected pattern mode on int column to fail, got %v", err)
+	}
+}
+
+func TestApplyFilterBindsTypedArgs(t *testing.T) {
+	f := &Filter{Conditions: []FilterCondition{
+		{Column: "score", Mode: GreaterOrEqual, Value: "3", Args: []any{int64(3)}},
+	}}
+
+	_, args := writeQuery(t, ApplyFilter(habitsQuery(), f))
+	if len(args) != 1 || args[0] != int64(3) {
+		t.Fatalf("expected typed arg, got %#v", args)
+	}
+}
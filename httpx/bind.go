@@ -0,0 +1,268 @@
+package httpx
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRequired is reported for required values missing from the request.
+var ErrRequired = errors.New("is required")
+
+// FieldError describes a single request value that could not be bound.
+type FieldError struct {
+	Source string // "query", "path" or "header"
+	Name   string
+	Value  string
+	Err    error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s parameter %q: %v", e.Source, e.Name, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// BindError lists every value BindQuery could not bind.
+type BindError struct {
+	Fields []FieldError
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return "invalid request parameters: " + strings.Join(msgs, "; ")
+}
+
+// Problem returns a 400 problem listing every field, ready for WriteProblem.
+func (e *BindError) Problem() Problem {
+	params := make([]InvalidParam, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		params = append(params, InvalidParam{Name: f.Name, Value: f.Value, Reason: f.Err.Error()})
+	}
+	return Problem{
+		Title:         "Invalid request parameters",
+		Status:        http.StatusBadRequest,
+		InvalidParams: params,
+	}
+}
+
+var (
+	textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType    = reflect.TypeFor[time.Duration]()
+)
+
+// BindQuery fills a T from the request using struct tags:
+//
+//	type listLogs struct {
+//		HabitID uuid.UUID     `path:"habit_id"`
+//		Status  []string      `query:"status"`
+//		Since   *time.Time    `query:"since"`
+//		Window  time.Duration `query:"window" default:"24h"`
+//		Tenant  string        `header:"X-Tenant" required:"true"`
+//	}
+//
+// Fields can be strings, bools, numbers, time.Duration, types implementing
+// encoding.TextUnmarshaler, pointers to these (left nil when the value is
+// absent) and slices of these, filled from repeated values. default is used
+// when the value is absent and split on commas for slices. Embedded structs
+// are bound as well.
+//
+// Bad or missing values are returned together as a *BindError. Any other
+// error means T itself cannot be bound.
+func BindQuery[T any](r *http.Request) (T, error) {
+	var out T
+	v := reflect.ValueOf(&out).Elem()
+	if v.Kind() != reflect.Struct {
+		return out, fmt.Errorf("httpx: cannot bind into %s, need a struct", v.Type())
+	}
+
+	var bindErr BindError
+	if err := bindStruct(r, v, &bindErr); err != nil {
+		return out, err
+	}
+	if len(bindErr.Fields) > 0 {
+		return out, &bindErr
+	}
+	return out, nil
+}
+
+func bindStruct(r *http.Request, v reflect.Value, bindErr *BindError) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindStruct(r, v.Field(i), bindErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		source, name := fieldSource(field)
+		if source == "" {
+			continue
+		}
+		if !bindable(field.Type) {
+			return fmt.Errorf("httpx: field %s: unsupported type %s", field.Name, field.Type)
+		}
+		values := requestValues(r, source, name)
+		if len(values) == 0 {
+			if def, ok := field.Tag.Lookup("default"); ok {
+				values = []string{def}
+				if field.Type.Kind() == reflect.Slice && !isScalar(field.Type) {
+					values = strings.Split(def, ",")
+				}
+			}
+		}
+		if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+			if field.Tag.Get("required") == "true" {
+				bindErr.Fields = append(bindErr.Fields, FieldError{Source: source, Name: name, Err: ErrRequired})
+			}
+			continue
+		}
+
+		if err := setField(v.Field(i), values); err != nil {
+			bindErr.Fields = append(bindErr.Fields, FieldError{
+				Source: source,
+				Name:   name,
+				Value:  strings.Join(values, ","),
+				Err:    err,
+			})
+		}
+	}
+	return nil
+}
+
+// fieldSource returns where a field is read from and under which name.
+func fieldSource(field reflect.StructField) (string, string) {
+	for _, source := range []string{"query", "path", "header"} {
+		if name, ok := field.Tag.Lookup(source); ok && name != "-" {
+			if name == "" {
+				name = field.Name
+			}
+			return source, name
+		}
+	}
+	return "", ""
+}
+
+func requestValues(r *http.Request, source, name string) []string {
+	switch source {
+	case "query":
+		return r.URL.Query()[name]
+	case "path":
+		if value := r.PathValue(name); value != "" {
+			return []string{value}
+		}
+	case "header":
+		return r.Header.Values(name)
+	}
+	return nil
+}
+
+// bindable reports whether setField can fill a field of type t.
+func bindable(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && !isScalar(t) {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isScalar(t) || t == durationType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isScalar reports whether t is bound from a single value even though it
+// may be a slice, like net.IP.
+func isScalar(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshaler)
+}
+
+func setField(v reflect.Value, values []string) error {
+	if v.Kind() != reflect.Slice || isScalar(v.Type()) {
+		return setValue(v, values[len(values)-1])
+	}
+	slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+	for i, raw := range values {
+		if err := setValue(slice.Index(i), raw); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("must be a duration such as 90s or 1h30m")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+type pageQuery struct {
+	Limit int `query:"limit" default:"20"`
+}
+
+type listLogsQuery struct {
+	pageQuery
+	HabitID uuid.UUID     `path:"habit_id"`
+	Status  []string      `query:"status" default:"active,paused"`
+	Since   *time.Time    `query:"since"`
+	Until   *time.Time    `query:"until"`
+	Window  time.Duration `query:"window" default:"24h"`
+	Done    bool          `query:"done"`
+	Scores  []float64     `query:"score"`
+	Tenant  string        `header:"X-Tenant" required:"true"`
+	ignored string
+}
+
+func TestBindQuery(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+	var got listLogsQuery
+	var err error
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /habits/{habit_id}/logs", func(w http.ResponseWriter, r *http.Request) {
+		got, err = BindQuery[listLogsQuery](r)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/habits/"+id.String()+"/logs?since=2024-05-01T00:00:00Z&done=true&score=1.5&score=3", nil)
+	req.Header.Set("X-Tenant", "acme")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	since := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	if got.HabitID != id || got.Since == nil || !got.Since.Equal(since) || got.Until != nil {
+		t.Fatalf("unexpected path/time values: %+v", got)
+	}
+	if got.Limit != 20 || got.Window != 24*time.Hour || !reflect.DeepEqual(got.Status, []string{"active", "paused"}) {
+		t.Fatalf("expected defaults, got %+v", got)
+	}
+	if !got.Done || !reflect.DeepEqual(got.Scores, []float64{1.5, 3}) || got.Tenant != "acme" {
+		t.Fatalf("unexpected values: %+v", got)
+	}
+}
+
+func TestBindQueryErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/logs?limit=ten&since=yesterday&score=1&score=x", nil)
+
+	_, err := BindQuery[listLogsQuery](req)
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("expected *BindError, got %v", err)
+	}
+
+	var names []string
+	for _, f := range bindErr.Fields {
+		names = append(names, f.Source+":"+f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"query:limit", "query:since", "query:score", "header:X-Tenant"}) {
+		t.Fatalf("unexpected field errors: %v", bindErr.Fields)
+	}
+	if !errors.Is(bindErr.Fields[3], ErrRequired) {
+		t.Fatalf("expected missing header to be required, got %v", bindErr.Fields[3])
+	}
+
+	problem := bindErr.Problem()
+	if problem.Status != http.StatusBadRequest || len(problem.InvalidParams) != 4 || problem.InvalidParams[0].Reason != "must be an integer" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+}
+
+func TestBindQueryUnsupportedType(t *testing.T) {
+	type bad struct {
+		Filter map[string]string `query:"filter"`
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	_, err := BindQuery[bad](req)
+	var bindErr *BindError
+	if err == nil || errors.As(err, &bindErr) {
+		t.Fatalf("expected a type error, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, Problem{
+		Status:        http.StatusBadRequest,
+		InvalidParams: []InvalidParam{{Name: "limit", Value: "-1", Reason: "must be a non-negative integer"}},
+	})
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Fatalf("expected problem content type, got %q", ct)
+	}
+
+	var body Problem
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Title != "Bad Request" || len(body.InvalidParams) != 1 || body.InvalidParams[0].Name != "limit" {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+// WithClock sets the clock relative date filters are resolved against.
+// Defaults to time.Now.
+func WithClock(now func() time.Time) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		if now != nil {
+			c.clock = now
+		}
+	}
+}
+
+// WithTimeZone sets how the time zone of a request is found, e.g. from a
+// header or the user's settings. Calendar filters such as today or
+// this_month start at midnight in that zone. Defaults to UTC; a nil
+// location also means UTC.
+func WithTimeZone(zone func(*http.Request) *time.Location) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		if zone != nil {
+			c.zone = zone
+		}
+	}
+}
+
+// TimeZoneHeader reads an IANA time zone name such as Europe/Warsaw from
+// the named request header. Unknown zones fall back to UTC.
+func TimeZoneHeader(name string) func(*http.Request) *time.Location {
+	return func(r *http.Request) *time.Location {
+		loc, err := time.LoadLocation(strings.TrimSpace(r.Header.Get(name)))
+		if err != nil {
+			return time.UTC
+		}
+		return loc
+	}
+}
+
+// now returns the current time in the request's time zone. r is nil when
+// parsing values without a request.
+func (c *queryParamsConfig) now(r *http.Request) time.Time {
+	now := time.Now
+	if c.clock != nil {
+		now = c.clock
+	}
+	loc := time.UTC
+	if c.zone != nil && r != nil {
+		if zone := c.zone(r); zone != nil {
+			loc = zone
+		}
+	}
+	return now().In(loc)
+}
+
+// resolveDates replaces relative date values (-7d, P1M, today, ...) and
+// Within periods (this_month, last_week, ...) with concrete bounds. With a
+// schema this only applies to time and date columns, and the bounds are
+// left for Schema.Coerce to parse; without one the bounds are bound as
+// time.Time.
+func (c *queryParamsConfig) resolveDates(cond data.FilterCondition, now time.Time) (data.FilterCondition, error) {
+	layout := time.RFC3339Nano
+	if c.schema != nil {
+		column, _ := c.schema.Lookup(cond.Column)
+		switch column.Type {
+		case data.TypeTime:
+		case data.TypeDate:
+			layout = time.DateOnly
+		default:
+			return cond, nil
+		}
+	}
+
+	values := cond.Values()
+	switch cond.Mode {
+	case data.Within:
+		if len(values) == 1 {
+			start, end, ok := data.ResolvePeriod(values[0], now)
+			if !ok {
+				return cond, fmt.Errorf("unknown period %q", values[0])
+			}
+			return c.withBounds(cond, layout, start, end), nil
+		}
+		if len(values) != 2 {
+			return cond, errors.New("mode Within needs a period or two comma separated bounds")
+		}
+	case data.GreaterThan, data.GreaterOrEqual, data.LessThan, data.LessOrEqual, data.Between:
+		relative := false
+		for _, v := range values {
+			if _, ok := data.ResolveTime(v, now); ok {
+				relative = true
+			}
+		}
+		if !relative {
+			return cond, nil
+		}
+	default:
+		return cond, nil
+	}
+
+	bounds := make([]time.Time, 0, len(values))
+	for _, v := range values {
+		if t, ok := data.ResolveTime(v, now); ok {
+			bounds = append(bounds, t)
+			continue
+		}
+		t, err := parseTime(v, now.Location())
+		if err != nil {
+			return cond, err
+		}
+		bounds = append(bounds, t)
+	}
+	return c.withBounds(cond, layout, bounds...), nil
+}
+
+// withBounds stores resolved times in cond, formatted with layout for the
+// schema to parse, or as Args when there is no schema.
+func (c *queryParamsConfig) withBounds(cond data.FilterCondition, layout string, bounds ...time.Time) data.FilterCondition {
+	parts := make([]string, 0, len(bounds))
+	args := make([]any, 0, len(bounds))
+	for _, t := range bounds {
+		parts = append(parts, t.Format(layout))
+		args = append(args, t)
+	}
+	cond.Value = strings.Join(parts, ",")
+	if c.schema == nil {
+		cond.Args = args
+	}
+	return cond
+}
+
+// parseTime parses an absolute RFC 3339 timestamp or a date, which is taken
+// as midnight in loc.
+func parseTime(raw string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, raw, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a relative date, RFC 3339 timestamp or YYYY-MM-DD date", raw)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+func TestParseQueryParamsRelativeDates(t *testing.T) {
+	now := time.Date(2024, time.May, 15, 10, 0, 0, 0, time.UTC)
+	cfg := &queryParamsConfig{
+		clock: func() time.Time { return now },
+		schema: data.NewSchema(
+			data.Column{Name: "completed_at", Filterable: true, Type: data.TypeTime},
+			data.Column{Name: "due_on", Filterable: true, Type: data.TypeDate},
+			data.Column{Name: "name", Filterable: true},
+		),
+	}
+	values := url.Values{"filter": {
+		"completed_at_after=-7d",
+		"completed_at_within=this_month",
+		"due_on_before=P1W",
+		"name_eq=today",
+	}}
+
+	params, errs := cfg.parse(values)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	after := params.Conditions[0]
+	if after.Mode != data.GreaterThan || after.Args[0] != time.Date(2024, time.May, 8, 10, 0, 0, 0, time.UTC) {
+		t.Fatalf("unexpected after condition: %+v", after)
+	}
+	within := params.Conditions[1]
+	if within.Mode != data.Within || within.Value != "2024-05-01T00:00:00Z,2024-06-01T00:00:00Z" || len(within.Args) != 2 {
+		t.Fatalf("unexpected within condition: %+v", within)
+	}
+	before := params.Conditions[2]
+	if before.Mode != data.LessThan || before.Value != "2024-05-08" {
+		t.Fatalf("unexpected before condition: %+v", before)
+	}
+	if name := params.Conditions[3]; name.Value != "today" {
+		t.Fatalf("expected text column to keep its value, got %+v", name)
+	}
+}
+
+func TestParseQueryParamsRelativeDateErrors(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "completed_at", Filterable: true, Type: data.TypeTime},
+		data.Column{Name: "name", Filterable: true},
+	)}
+
+	_, errs := cfg.parse(url.Values{"filter": {"completed_at_within=someday", "name_within=this_week"}})
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if errs[0].Reason != `unknown period "someday"` {
+		t.Fatalf("unexpected reason: %q", errs[0].Reason)
+	}
+}
+
+func TestQueryParamsTimeZone(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	now := time.Date(2024, time.May, 14, 23, 30, 0, 0, time.UTC) // already May 15 in Warsaw
+
+	var got *data.QueryParams
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = QueryParamsFromContext(r.Context())
+	})
+	mw := QueryParamsMiddleware(
+		WithClock(func() time.Time { return now }),
+		WithTimeZone(TimeZoneHeader("X-Time-Zone")),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/logs?filter=completed_at_within=today", nil)
+	req.Header.Set("X-Time-Zone", "Europe/Warsaw")
+	mw(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if got == nil || len(got.Conditions) != 1 {
+		t.Fatalf("expected one condition, got %+v", got)
+	}
+	start := got.Conditions[0].Args[0].(time.Time)
+	if !start.Equal(time.Date(2024, time.May, 15, 0, 0, 0, 0, warsaw)) {
+		t.Fatalf("expected today to start at midnight in Warsaw, got %v", start)
+	}
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+// maxFilterDepth bounds the nesting of filter expressions.
+const maxFilterDepth = 8
+
+// isFilterExpr reports whether a filter param uses the expression grammar
+// rather than a plain column_mode=value condition.
+func isFilterExpr(raw string) bool {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	for _, op := range []string{"and(", "or(", "not("} {
+		if strings.HasPrefix(raw, op) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilterExpr parses the boolean filter grammar:
+//
+//	expr := and(expr, ...) | or(expr, ...) | not(expr) | column_mode=value
+//
+// Values containing commas, parentheses or surrounding spaces can be double
+// quoted, with \" and \\ as escapes: or(name_eq="a, b",status_in="x,y").
+func parseFilterExpr(raw string) (data.FilterExpr, error) {
+	return parseFilterExprWith(raw, func(raw string) (data.FilterCondition, error) {
+		return parseFilterCondition(raw, nil)
+	})
+}
+
+// parseFilterExprWith parses an expression with condition parsing its leaves.
+func parseFilterExprWith(raw string, condition func(string) (data.FilterCondition, error)) (data.FilterExpr, error) {
+	p := &exprParser{src: raw, condition: condition}
+	e, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	src       string
+	pos       int
+	condition func(string) (data.FilterCondition, error)
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// operator consumes "and(", "or(" or "not(" and returns the operator name.
+func (p *exprParser) operator() (string, bool) {
+	rest := strings.ToLower(p.src[p.pos:])
+	for _, op := range []string{"and", "or", "not"} {
+		if strings.HasPrefix(rest, op+"(") {
+			p.pos += len(op) + 1
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseExpr(depth int) (data.FilterExpr, error) {
+	if depth > maxFilterDepth {
+		return nil, p.errorf("expression nested deeper than %d", maxFilterDepth)
+	}
+	p.skipSpace()
+	op, ok := p.operator()
+	if !ok {
+		return p.parseLeaf()
+	}
+
+	var operands []data.FilterExpr
+	for {
+		e, err := p.parseExpr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, e)
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("missing ')'")
+		}
+		if p.src[p.pos] == ')' {
+			p.pos++
+			break
+		}
+		if p.src[p.pos] != ',' {
+			return nil, p.errorf("expected ',' or ')'")
+		}
+		p.pos++
+	}
+
+	switch op {
+	case "and":
+		return data.AndExpr(operands), nil
+	case "or":
+		return data.OrExpr(operands), nil
+	default:
+		if len(operands) != 1 {
+			return nil, p.errorf("not() takes exactly one operand")
+		}
+		return data.NotExpr{Expr: operands[0]}, nil
+	}
+}
+
+// parseLeaf reads a column_mode=value condition up to the next top level
+// ',' or ')'.
+func (p *exprParser) parseLeaf() (data.FilterExpr, error) {
+	start := p.pos
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ',' || c == ')' {
+			break
+		}
+		if c == '(' {
+			return nil, p.errorf("unexpected '(' in condition, quote the value")
+		}
+		if c != '"' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		quoted, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(quoted)
+	}
+
+	cond, err := p.condition(strings.TrimSpace(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("at position %d: %w", start, err)
+	}
+	return cond, nil
+}
+
+func (p *exprParser) parseQuoted() (string, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.pos >= len(p.src) {
+				return "", p.errorf("unterminated escape")
+			}
+			b.WriteByte(p.src[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated quoted value")
+}
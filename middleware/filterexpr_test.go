@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+func TestParseFilterExpr(t *testing.T) {
+	e, err := parseFilterExpr(`and(or(status_eq=active, status_eq=archived), not(name_contains="gym, inc"))`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	want := data.AndExpr{
+		data.OrExpr{
+			data.FilterCondition{Column: "status", Mode: data.Exact, Value: "active"},
+			data.FilterCondition{Column: "status", Mode: data.Exact, Value: "archived"},
+		},
+		data.NotExpr{Expr: data.FilterCondition{Column: "name", Mode: data.Anywhere, Value: "gym, inc"}},
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Fatalf("expected %v, got %v", want, e)
+	}
+}
+
+func TestParseFilterExprErrors(t *testing.T) {
+	for _, raw := range []string{
+		`or(status_eq=active`,
+		`not(a_eq=1,b_eq=2)`,
+		`or(name_eq=a(b))`,
+		`or(name_eq="open)`,
+		`or(status_eq=active) trailing`,
+		`not(not(not(not(not(not(not(not(not(a_eq=1)))))))))`,
+	} {
+		if _, err := parseFilterExpr(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseQueryParamsFilterExprSchema(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "status", Filterable: true},
+		data.Column{Name: "score", Filterable: true, Type: data.TypeInt},
+	)}
+
+	values := url.Values{}
+	values.Add("filter", "or(status_eq=active,score_gte=3)")
+	values.Add("filter", "or(status_eq=active,secret_eq=1)")
+
+	params, errs := cfg.parse(values)
+	if params == nil || len(params.Exprs) != 1 {
+		t.Fatalf("expected one valid expression, got %+v", params)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected the undeclared column to be reported, got %v", errs)
+	}
+
+	or := params.Exprs[0].(data.OrExpr)
+	if got := or[1].(data.FilterCondition).Args; !reflect.DeepEqual(got, []any{int64(3)}) {
+		t.Fatalf("expected leaf values to be coerced, got %#v", got)
+	}
+}
@@ -3,17 +3,41 @@ package middleware
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/tschuyebuhl/httpkit/userctx"
 )
 
+// Errors returned by Keycloak.Verify, each wrapped with the details of the
+// rejection.
+var (
+	ErrTokenInvalid         = errors.New("keycloak: invalid token")
+	ErrTokenAlgorithm       = errors.New("keycloak: signing algorithm not allowed")
+	ErrTokenExpired         = errors.New("keycloak: token expired")
+	ErrTokenNotYetValid     = errors.New("keycloak: token not yet valid")
+	ErrTokenAudience        = errors.New("keycloak: audience not allowed")
+	ErrTokenAuthorizedParty = errors.New("keycloak: authorized party not allowed")
+	ErrTokenType            = errors.New("keycloak: token type not allowed")
+)
+
 type Keycloak struct {
 	verifier    *oidc.IDTokenVerifier
 	tokenMapper TokenMapper
+
+	audiences         []string
+	authorizedParties []string
+	tokenType         string
+	algorithms        []string
+	clockSkew         *time.Duration
+	now               func() time.Time
 }
 
 type TokenMapper func(ctx context.Context, token *oidc.IDToken) (context.Context, error)
@@ -28,23 +52,73 @@ func WithTokenMapper(mapper TokenMapper) KeycloakOption {
 	}
 }
 
+// WithAudience accepts only tokens whose aud claim contains at least one of
+// audiences. Without it tokens minted for any client of the realm pass.
+func WithAudience(audiences ...string) KeycloakOption {
+	return func(a *Keycloak) {
+		a.audiences = append(a.audiences, audiences...)
+	}
+}
+
+// WithAuthorizedParty accepts only tokens whose azp claim, the client the
+// token was issued to, is one of clients.
+func WithAuthorizedParty(clients ...string) KeycloakOption {
+	return func(a *Keycloak) {
+		a.authorizedParties = append(a.authorizedParties, clients...)
+	}
+}
+
+// WithTokenType accepts only tokens with the given typ claim. Keycloak marks
+// access tokens "Bearer" and ID tokens "ID", so WithTokenType("Bearer")
+// rejects ID tokens sent as credentials.
+func WithTokenType(typ string) KeycloakOption {
+	return func(a *Keycloak) {
+		a.tokenType = typ
+	}
+}
+
+// WithSigningAlgorithms restricts the algorithms tokens may be signed with,
+// e.g. oidc.RS256. It defaults to the ones the provider advertises.
+func WithSigningAlgorithms(algs ...string) KeycloakOption {
+	return func(a *Keycloak) {
+		a.algorithms = append(a.algorithms, algs...)
+	}
+}
+
+// defaultNotBeforeLeeway is the nbf leeway used without WithClockSkew, the
+// one go-oidc applies.
+const defaultNotBeforeLeeway = 5 * time.Minute
+
+// WithClockSkew tolerates clocks drifting by up to skew when checking the
+// exp and nbf claims. Without it exp is checked exactly and nbf with
+// go-oidc's 5 minute leeway.
+func WithClockSkew(skew time.Duration) KeycloakOption {
+	return func(a *Keycloak) {
+		if skew >= 0 {
+			a.clockSkew = &skew
+		}
+	}
+}
+
 func NewKeycloak(provider *oidc.Provider, opts ...KeycloakOption) *Keycloak {
-	cfg := &Keycloak{
+	k := &Keycloak{
 		tokenMapper: defaultTokenMapper,
+		now:         time.Now,
 	}
 	for _, opt := range opts {
-		opt(cfg)
+		opt(k)
 	}
 
-	var verifier *oidc.IDTokenVerifier
 	if provider != nil {
-		verifier = provider.Verifier(&oidc.Config{ClientID: "", SkipClientIDCheck: true})
-	}
-
-	return &Keycloak{
-		verifier:    verifier,
-		tokenMapper: cfg.tokenMapper,
+		// Audience and expiry are checked in Verify, with their own errors
+		// and the configured clock skew.
+		k.verifier = provider.Verifier(&oidc.Config{
+			SkipClientIDCheck:    true,
+			SkipExpiryCheck:      true,
+			SupportedSigningAlgs: k.algorithms,
+		})
 	}
+	return k
 }
 
 func (k *Keycloak) Handler(next http.Handler) http.Handler {
@@ -83,13 +157,13 @@ func (k *Keycloak) serve(w http.ResponseWriter, r *http.Request, next http.Handl
 		return
 	}
 
-	idToken, err := k.verifier.Verify(r.Context(), tokenString)
+	idToken, err := k.Verify(r.Context(), tokenString)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error verifying token: %s", err), http.StatusUnauthorized)
 		return
 	}
 
-	ctx := r.Context()
+	ctx := WithKeycloakClaims(r.Context(), parseKeycloakClaims(idToken))
 	if k.tokenMapper != nil {
 		mappedCtx, err := k.tokenMapper(ctx, idToken)
 		if err != nil {
@@ -102,6 +176,82 @@ func (k *Keycloak) serve(w http.ResponseWriter, r *http.Request, next http.Handl
 	next.ServeHTTP(w, r.WithContext(ctx))
 }
 
+// Verify checks a raw token the way the middleware does: signature and
+// issuer, then expiry and the configured audience, authorized party, token
+// type and signing algorithms. Rejections wrap one of the ErrToken errors.
+func (k *Keycloak) Verify(ctx context.Context, raw string) (*oidc.IDToken, error) {
+	if k.verifier == nil {
+		return nil, fmt.Errorf("%w: OIDC provider is required", ErrTokenInvalid)
+	}
+	if len(k.algorithms) > 0 {
+		// Checked before the verifier, which reports it as a malformed jwt.
+		alg, err := signingAlgorithm(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, err)
+		}
+		if !slices.Contains(k.algorithms, alg) {
+			return nil, fmt.Errorf("%w: %q", ErrTokenAlgorithm, alg)
+		}
+	}
+
+	token, err := k.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, err)
+	}
+	var claims struct {
+		Type            string  `json:"typ"`
+		AuthorizedParty string  `json:"azp"`
+		NotBefore       float64 `json:"nbf"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, err)
+	}
+
+	now := k.now()
+	expLeeway, nbfLeeway := time.Duration(0), defaultNotBeforeLeeway
+	if k.clockSkew != nil {
+		expLeeway, nbfLeeway = *k.clockSkew, *k.clockSkew
+	}
+	if now.Add(-expLeeway).After(token.Expiry) {
+		return nil, fmt.Errorf("%w: expired at %s", ErrTokenExpired, token.Expiry.Format(time.RFC3339))
+	}
+	// Keycloak writes nbf 0 when the claim is unset.
+	if nbf := time.Unix(int64(claims.NotBefore), 0); claims.NotBefore > 0 && now.Add(nbfLeeway).Before(nbf) {
+		return nil, fmt.Errorf("%w: valid from %s", ErrTokenNotYetValid, nbf.Format(time.RFC3339))
+	}
+	if len(k.audiences) > 0 && !slices.ContainsFunc(token.Audience, func(aud string) bool {
+		return slices.Contains(k.audiences, aud)
+	}) {
+		return nil, fmt.Errorf("%w: %q", ErrTokenAudience, token.Audience)
+	}
+	if len(k.authorizedParties) > 0 && !slices.Contains(k.authorizedParties, claims.AuthorizedParty) {
+		return nil, fmt.Errorf("%w: %q", ErrTokenAuthorizedParty, claims.AuthorizedParty)
+	}
+	if k.tokenType != "" && !strings.EqualFold(claims.Type, k.tokenType) {
+		return nil, fmt.Errorf("%w: expected %q, got %q", ErrTokenType, k.tokenType, claims.Type)
+	}
+	return token, nil
+}
+
+// signingAlgorithm reads the alg header of a compact JWS without verifying it.
+func signingAlgorithm(raw string) (string, error) {
+	header, _, ok := strings.Cut(raw, ".")
+	if !ok {
+		return "", errors.New("malformed jwt")
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return "", fmt.Errorf("malformed jwt header: %w", err)
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(decoded, &h); err != nil {
+		return "", fmt.Errorf("malformed jwt header: %w", err)
+	}
+	return h.Alg, nil
+}
+
 func defaultTokenMapper(ctx context.Context, token *oidc.IDToken) (context.Context, error) {
 	return userctx.WithUserID(ctx, token.Subject), nil
 }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -80,3 +82,130 @@ func TestKeycloakSetsUserID(t *testing.T) {
 		t.Fatalf("expected body user-1, got %q", rec.Body.String())
 	}
 }
+
+func TestKeycloakVerifyRejections(t *testing.T) {
+	provider, issuer, sign := newTestIssuer(t)
+	now := time.Now()
+	claims := func(extra string) string {
+		return fmt.Sprintf(`{"iss":"%s","sub":"user-1","exp":%d%s}`, issuer, now.Add(time.Hour).Unix(), extra)
+	}
+	strict := []KeycloakOption{
+		WithAudience("habits-api"),
+		WithAuthorizedParty("habits-web"),
+		WithTokenType("Bearer"),
+	}
+	valid := `,"aud":["habits-api","account"],"azp":"habits-web","typ":"Bearer"`
+	// The payload of one token with the signature of another.
+	signed := strings.Split(sign(claims(valid)), ".")
+	other := strings.Split(sign(claims(`,"aud":"other"`)), ".")
+	tampered := signed[0] + "." + signed[1] + "." + other[2]
+
+	tests := []struct {
+		name  string
+		opts  []KeycloakOption
+		token string
+		err   error
+	}{
+		{"valid", strict, sign(claims(valid)), nil},
+		{"unchecked by default", nil, sign(claims(`,"aud":"other","typ":"ID"`)), nil},
+		{"other audience", strict, sign(claims(`,"aud":"other","azp":"habits-web","typ":"Bearer"`)), ErrTokenAudience},
+		{"other authorized party", strict, sign(claims(`,"aud":"habits-api","azp":"admin-cli","typ":"Bearer"`)), ErrTokenAuthorizedParty},
+		{"id token", strict, sign(claims(`,"aud":"habits-api","azp":"habits-web","typ":"ID"`)), ErrTokenType},
+		{"algorithm", []KeycloakOption{WithSigningAlgorithms(oidc.ES256)}, sign(claims(valid)), ErrTokenAlgorithm},
+		{"allowed algorithm", []KeycloakOption{WithSigningAlgorithms(oidc.ES256, oidc.RS256)}, sign(claims(valid)), nil},
+		{"bad signature", nil, tampered, ErrTokenInvalid},
+		{"malformed", nil, "not-a-jwt", ErrTokenInvalid},
+		{
+			"expired",
+			nil,
+			sign(fmt.Sprintf(`{"iss":"%s","sub":"user-1","exp":%d}`, issuer, now.Add(-30*time.Second).Unix())),
+			ErrTokenExpired,
+		},
+		{
+			"expired within skew",
+			[]KeycloakOption{WithClockSkew(time.Minute)},
+			sign(fmt.Sprintf(`{"iss":"%s","sub":"user-1","exp":%d}`, issuer, now.Add(-30*time.Second).Unix())),
+			nil,
+		},
+		{"nbf within default leeway", nil, sign(claims(fmt.Sprintf(`,"nbf":%d`, now.Add(time.Minute).Unix()))), nil},
+		{"not yet valid", nil, sign(claims(fmt.Sprintf(`,"nbf":%d`, now.Add(10*time.Minute).Unix()))), ErrTokenNotYetValid},
+		{
+			"not yet valid within skew",
+			[]KeycloakOption{WithClockSkew(time.Minute)},
+			sign(claims(fmt.Sprintf(`,"nbf":%d`, now.Add(30*time.Second).Unix()))),
+			nil,
+		},
+		{
+			"not yet valid beyond skew",
+			[]KeycloakOption{WithClockSkew(10 * time.Second)},
+			sign(claims(fmt.Sprintf(`,"nbf":%d`, now.Add(time.Minute).Unix()))),
+			ErrTokenNotYetValid,
+		},
+		{"nbf unset", nil, sign(claims(`,"nbf":0`)), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeycloak(provider, tt.opts...).Verify(context.Background(), tt.token)
+			if tt.err == nil && err != nil {
+				t.Fatalf("expected token to verify, got %v", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestKeycloakRejectsIDTokens(t *testing.T) {
+	provider, issuer, sign := newTestIssuer(t)
+	token := sign(fmt.Sprintf(`{"iss":"%s","aud":"habits-web","sub":"user-1","typ":"ID","exp":%d}`,
+		issuer, time.Now().Add(time.Hour).Unix(),
+	))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called with an ID token")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/habits", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	NewKeycloak(provider, WithTokenType("Bearer")).Handler(handler).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), ErrTokenType.Error()) {
+		t.Fatalf("expected token type error, got %q", rec.Body.String())
+	}
+}
+
+// newTestIssuer starts an OIDC test server and returns its provider, issuer
+// URL and a function signing raw claims with its key.
+func newTestIssuer(t *testing.T) (*oidc.Provider, string, func(string) string) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	oidcServer := &oidctest.Server{
+		PublicKeys: []oidctest.PublicKey{
+			{
+				PublicKey: priv.Public(),
+				KeyID:     "test-key",
+				Algorithm: oidc.RS256,
+			},
+		},
+	}
+	srv := httptest.NewServer(oidcServer)
+	t.Cleanup(srv.Close)
+	oidcServer.SetIssuer(srv.URL)
+
+	provider, err := oidc.NewProvider(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	return provider, srv.URL, func(claims string) string {
+		return oidctest.SignIDToken(priv, "test-key", oidc.RS256, claims)
+	}
+}
@@ -2,28 +2,182 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tschuyebuhl/httpkit/data"
+	"github.com/tschuyebuhl/httpkit/httpx"
 )
 
 type queryParamsKey struct{}
 
+type queryParamsConfig struct {
+	schema       *data.Schema
+	strict       bool
+	defaultLimit int64
+	maxLimit     int64
+	allowAll     *bool
+	syntax       Syntax
+	// caseSensitivePatterns keeps contains, starts_with and ends_with
+	// case-sensitive unless prefixed with "i".
+	caseSensitivePatterns bool
+	clock                 func() time.Time
+	zone                  func(*http.Request) *time.Location
+
+	// at is the time relative dates resolve against, set per request.
+	at time.Time
+}
+
+type QueryParamsOption func(*queryParamsConfig)
+
+// WithSchema restricts filters and sorting to the columns declared in schema.
+// Conditions on undeclared columns are rejected like other invalid filters.
+func WithSchema(schema *data.Schema) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		if schema != nil {
+			c.schema = schema
+		}
+	}
+}
+
+// WithStrict rejects requests with any malformed query param with a 400
+// application/problem+json response listing every bad parameter. When strict
+// is false (the default) bad parameters are dropped and the rest applied,
+// except filters: dropping one would widen the result, so an invalid filter
+// is a 400 in either mode.
+func WithStrict(strict bool) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		c.strict = strict
+	}
+}
+
+// WithCaseSensitivePatterns makes the contains, starts_with and ends_with
+// filter modes match case, like LIKE; their "i" variants still ignore it.
+// By default all three ignore case, like ILIKE.
+func WithCaseSensitivePatterns() QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		c.caseSensitivePatterns = true
+	}
+}
+
+// WithDefaultLimit sets the page size used when the request has no limit.
+func WithDefaultLimit(limit int64) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		if limit > 0 {
+			c.defaultLimit = limit
+		}
+	}
+}
+
+// WithMaxLimit caps the page size a client can request. Larger limits are
+// clamped (or rejected in strict mode). Setting a maximum also disallows
+// limit=ALL unless WithAllowAll(true) is given.
+func WithMaxLimit(limit int64) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		if limit > 0 {
+			c.maxLimit = limit
+		}
+	}
+}
+
+// WithAllowAll controls whether clients may request limit=ALL when a default
+// or maximum limit is configured.
+func WithAllowAll(allow bool) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		c.allowAll = &allow
+	}
+}
+
 func QueryParams(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		params := parseQueryParams(r.URL.Query())
-		if params != nil {
-			ctx := context.WithValue(r.Context(), queryParamsKey{}, params)
+	return QueryParamsMiddleware()(next)
+}
+
+// QueryParamsMiddleware parses the query params of every request it handles
+// and stores them for QueryParamsFromContext. It is meant for httpx.Route.Use
+// (or httpx.Use on a group), so each route gets its own schema, limits,
+// syntax and strictness. Options layer over the ones of an outer
+// QueryParamsMiddleware or QueryParamsDefaults, and the params it stores
+// replace the outer ones.
+func QueryParamsMiddleware(opts ...QueryParamsOption) func(http.Handler) http.Handler {
+	own := newQueryParamsConfig(nil, opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := own
+			if outer := queryParamsConfigFrom(r.Context()); outer != nil {
+				cfg = newQueryParamsConfig(outer, opts)
+			}
+
+			params, errs := cfg.parseAt(r.URL.Query(), cfg.now(r))
+			if len(errs) > 0 && (cfg.strict || hasFilterErrors(errs)) {
+				httpx.WriteProblem(w, httpx.Problem{
+					Title:         "Invalid query parameters",
+					Status:        http.StatusBadRequest,
+					Instance:      r.URL.Path,
+					InvalidParams: errs,
+				})
+				return
+			}
+			if params != nil && cfg.limited() {
+				w.Header().Set("X-Page-Limit", fmt.Sprint(params.Limit))
+				if cfg.maxLimit > 0 {
+					w.Header().Set("X-Page-Max-Limit", strconv.FormatInt(cfg.maxLimit, 10))
+				}
+			}
+			ctx := context.WithValue(r.Context(), queryParamsConfigKey{}, cfg)
+			ctx = context.WithValue(ctx, queryParamsKey{}, params)
 			next.ServeHTTP(w, r.WithContext(ctx))
-			return
-		}
-		next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasFilterErrors reports whether any filter param was rejected, including
+// filters in the bracket syntax.
+func hasFilterErrors(errs []httpx.InvalidParam) bool {
+	return slices.ContainsFunc(errs, func(e httpx.InvalidParam) bool {
+		return e.Name == "filter" || strings.HasPrefix(e.Name, "filter[")
 	})
 }
 
+// QueryParamsDefaults sets options shared by the QueryParamsMiddleware of
+// every route below it, without parsing anything itself. Install it
+// globally instead of QueryParamsMiddleware so static assets and health
+// checks are not parsed (or rejected in strict mode).
+func QueryParamsDefaults(opts ...QueryParamsOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := newQueryParamsConfig(queryParamsConfigFrom(r.Context()), opts)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), queryParamsConfigKey{}, cfg)))
+		})
+	}
+}
+
+type queryParamsConfigKey struct{}
+
+func queryParamsConfigFrom(ctx context.Context) *queryParamsConfig {
+	cfg, _ := ctx.Value(queryParamsConfigKey{}).(*queryParamsConfig)
+	return cfg
+}
+
+// newQueryParamsConfig applies opts over a copy of base, if any.
+func newQueryParamsConfig(base *queryParamsConfig, opts []QueryParamsOption) *queryParamsConfig {
+	cfg := &queryParamsConfig{}
+	if base != nil {
+		*cfg = *base
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// QueryParamsFromContext returns the params parsed by the innermost
+// QueryParamsMiddleware, or nil when the request had none.
 func QueryParamsFromContext(ctx context.Context) *data.QueryParams {
 	if ctx == nil {
 		return nil
@@ -34,9 +188,41 @@ func QueryParamsFromContext(ctx context.Context) *data.QueryParams {
 	return nil
 }
 
+// paramErrors collects every problem found while parsing query params.
+type paramErrors []httpx.InvalidParam
+
+func (e *paramErrors) add(name, value string, err error) {
+	*e = append(*e, httpx.InvalidParam{Name: name, Value: value, Reason: err.Error()})
+}
+
 func parseQueryParams(values url.Values) *data.QueryParams {
-	if len(values) == 0 {
-		return nil
+	params, _ := (&queryParamsConfig{}).parse(values)
+	return params
+}
+
+// parse reads filters, sorting and pagination from values. Invalid params
+// are reported in the returned errors and left out of the params.
+func (c *queryParamsConfig) parse(values url.Values) (*data.QueryParams, []httpx.InvalidParam) {
+	return c.parseAt(values, c.now(nil))
+}
+
+// parseAt is parse with relative dates resolved against now.
+func (c *queryParamsConfig) parseAt(values url.Values, now time.Time) (*data.QueryParams, []httpx.InvalidParam) {
+	if len(values) == 0 && !c.limited() {
+		return nil, nil
+	}
+	request := *c
+	request.at = now
+	c = &request
+
+	var (
+		hasParams bool
+		errs      paramErrors
+	)
+	if c.syntax != nil {
+		var syntaxErrs []httpx.InvalidParam
+		values, syntaxErrs = c.syntax(values)
+		errs = append(errs, syntaxErrs...)
 	}
 
 	params := &data.QueryParams{
@@ -44,119 +230,519 @@ func parseQueryParams(values url.Values) *data.QueryParams {
 			Limit:  "ALL",
 			Offset: 0,
 		},
+		Schema: c.schema,
 	}
 
-	var hasParams bool
-
 	// Filters
 	if filters := values["filter"]; len(filters) > 0 {
-		conds := make([]data.FilterCondition, 0, len(filters))
+		var (
+			conds = make([]data.FilterCondition, 0, len(filters))
+			exprs []data.FilterExpr
+		)
 		for _, f := range filters {
-			if cond, ok := parseFilterCondition(f); ok {
-				conds = append(conds, cond)
+			if isFilterExpr(f) {
+				e, err := c.parseFilterExpr(f)
+				if err != nil {
+					errs.add("filter", f, err)
+					continue
+				}
+				exprs = append(exprs, e)
+				continue
 			}
+			cond, err := c.parseFilter(f)
+			if err != nil {
+				errs.add("filter", f, err)
+				continue
+			}
+			conds = append(conds, cond)
 		}
-		if len(conds) > 0 {
-			params.Filter = data.Filter{Conditions: conds}
+		if len(conds) > 0 || len(exprs) > 0 {
+			params.Filter = data.Filter{Conditions: conds, Exprs: exprs}
 			hasParams = true
 		}
 	}
 
+	// Grouping and aggregates
+	for _, raw := range values["group_by"] {
+		for _, part := range strings.Split(raw, ",") {
+			group, err := c.parseGroupKey(part)
+			if err != nil {
+				errs.add("group_by", part, err)
+				continue
+			}
+			params.GroupBy = append(params.GroupBy, group)
+		}
+	}
+	for _, raw := range values["agg"] {
+		for _, part := range strings.Split(raw, ",") {
+			agg, err := c.parseAggregate(part)
+			if err != nil {
+				errs.add("agg", part, err)
+				continue
+			}
+			params.Aggregates = append(params.Aggregates, agg)
+		}
+	}
+	if params.Aggregated() {
+		hasParams = true
+	}
+
 	// Sorting
-	if column, direction := parseSort(values.Get("sort")); column != "" {
-		params.Sort = data.Sort{Column: column, Direction: direction}
+	var keys []data.SortKey
+	for _, raw := range values["sort"] {
+		for _, part := range strings.Split(raw, ",") {
+			key, err := parseSortKey(part)
+			if err == nil && params.Aggregated() && !slices.Contains(params.Aliases(), key.Column) {
+				err = fmt.Errorf("%q is not a group or aggregate", key.Column)
+			} else if err == nil && !params.Aggregated() && !c.sortable(key.Column) {
+				err = fmt.Errorf("column %q is not sortable", key.Column)
+			}
+			if err != nil {
+				errs.add("sort", part, err)
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		params.Sort = data.Sort{Keys: keys}
 		hasParams = true
 	}
 
-	// Pagination
-	var (
-		numericLimit  int64
-		limitIsNumber bool
-		limitParam    = values.Get("limit")
-	)
+	// Full-text search
+	if search := strings.TrimSpace(values.Get("q")); search != "" {
+		params.Search = search
+		hasParams = true
+	}
+
+	// Sparse fieldsets
+	for _, raw := range values["fields"] {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if c.schema != nil {
+				if _, ok := c.schema.SelectColumn(field); !ok {
+					errs.add("fields", field, fmt.Errorf("unknown field %q", field))
+					continue
+				}
+			} else if !data.ValidColumn(field) {
+				errs.add("fields", field, fmt.Errorf("invalid field name %q", field))
+				continue
+			}
+			if !slices.Contains(params.Fields, field) {
+				params.Fields = append(params.Fields, field)
+			}
+		}
+	}
+	if len(params.Fields) > 0 {
+		hasParams = true
+	}
 
-	if limitValue, isNumber := parseLimit(limitParam); limitValue != nil {
+	// Pagination
+	limitParam := values.Get("limit")
+	limitValue := parseLimit(limitParam)
+	if limitValue != nil {
 		params.Limit = limitValue
 		hasParams = true
-		limitIsNumber = isNumber
-		if isNumber {
-			numericLimit = limitValue.(int64)
-		}
+	} else if values.Has("limit") {
+		errs.add("limit", limitParam, errors.New("must be a non-negative integer or ALL"))
+	}
+	if err := c.limitPage(&params.Pagination, limitValue != nil); err != nil {
+		errs.add("limit", limitParam, err)
 	}
+	numericLimit, limitIsNumber := params.Limit.(int64)
 
 	if offset, ok := parseInt(values.Get("offset")); ok {
 		params.Offset = offset
 		hasParams = true
+	} else if values.Has("offset") {
+		errs.add("offset", values.Get("offset"), errors.New("must be a non-negative integer"))
 	} else if page, ok := parseInt(values.Get("page")); ok && page > 0 && limitIsNumber {
 		params.Offset = (page - 1) * numericLimit
 		hasParams = true
+	} else if values.Has("page") {
+		errs.add("page", values.Get("page"), errors.New("must be a positive integer used with a numeric limit"))
 	}
 
-	if hasParams {
-		return params
+	// Keyset pagination
+	afterName := "after"
+	if !values.Has("after") && values.Has("cursor") {
+		afterName = "cursor"
+	}
+	for _, name := range []string{afterName, "before"} {
+		token := values.Get(name)
+		if !values.Has(name) {
+			continue
+		}
+		if params.Aggregated() {
+			errs.add(name, token, errors.New("cursors cannot be combined with group_by or agg"))
+			continue
+		}
+		if c.schema != nil && c.schema.RanksSearch(params.Search) {
+			errs.add(name, token, errors.New("cursors cannot be combined with ranked search"))
+			continue
+		}
+		cursor, err := c.parseCursor(token, params.Keys)
+		if err != nil {
+			errs.add(name, token, err)
+			continue
+		}
+		if params.Keyset() {
+			errs.add(name, token, errors.New("after and before cannot be combined"))
+			continue
+		}
+		if name == "before" {
+			params.Before = cursor
+		} else {
+			params.After = cursor
+		}
+		hasParams = true
+	}
+
+	if hasParams || c.limited() {
+		return params, errs
+	}
+	return nil, errs
+}
+
+// limited reports whether page size limits are configured, in which case
+// params are always produced so the limits apply to every request.
+func (c *queryParamsConfig) limited() bool {
+	return c.defaultLimit > 0 || c.maxLimit > 0
+}
+
+// allAllowed reports whether limit=ALL is accepted. Without a default or
+// maximum there is nothing to fall back to, so ALL is always allowed; with
+// either it is refused unless WithAllowAll(true) was given.
+func (c *queryParamsConfig) allAllowed() bool {
+	if !c.limited() {
+		return true
+	}
+	return c.allowAll != nil && *c.allowAll
+}
+
+func (c *queryParamsConfig) fallbackLimit() any {
+	switch {
+	case c.defaultLimit > 0 && c.maxLimit > 0:
+		return min(c.defaultLimit, c.maxLimit)
+	case c.defaultLimit > 0:
+		return c.defaultLimit
+	case c.allAllowed():
+		return "ALL"
+	default:
+		return c.maxLimit
+	}
+}
+
+// limitPage applies the default and maximum page size to pg. requested tells
+// whether the client asked for a valid limit; the returned error explains why
+// that limit was replaced.
+func (c *queryParamsConfig) limitPage(pg *data.Pagination, requested bool) error {
+	if limit, ok := pg.Limit.(int64); ok {
+		if c.maxLimit > 0 && limit > c.maxLimit {
+			pg.Limit = c.maxLimit
+			return fmt.Errorf("must not exceed %d", c.maxLimit)
+		}
+		return nil
+	}
+	if !requested {
+		pg.Limit = c.fallbackLimit()
+		return nil
+	}
+	if !c.allAllowed() {
+		pg.Limit = c.fallbackLimit()
+		return errors.New("ALL is not allowed")
 	}
 	return nil
 }
 
-func parseFilterCondition(raw string) (data.FilterCondition, bool) {
+// parseFilter parses a single filter and checks it against the schema.
+func (c *queryParamsConfig) parseFilter(raw string) (data.FilterCondition, error) {
+	cond, err := c.parseCondition(raw)
+	if err != nil {
+		return cond, err
+	}
+	return c.checkCondition(cond)
+}
+
+// parseCondition parses a column_mode=value condition. Without a schema a
+// key with no known mode suffix is an exact match on the whole key, so
+// "name_foo" may be a column or a mistyped mode. Strict mode reports such
+// keys and asks for an explicit mode, as in user_id_eq=5.
+func (c *queryParamsConfig) parseCondition(raw string) (data.FilterCondition, error) {
+	cond, err := parseFilterCondition(raw, c.schema)
+	if err != nil {
+		return cond, err
+	}
+	switch cond.Mode {
+	case data.Anywhere, data.Start, data.End:
+		cond.IgnoreCase = cond.IgnoreCase || !c.caseSensitivePatterns
+	}
+	if !c.strict || c.schema != nil {
+		return cond, nil
+	}
+	key, _, _ := strings.Cut(raw, "=")
+	if i := strings.LastIndex(cond.Column, "_"); i > 0 && cond.Column == strings.TrimSpace(key) {
+		return cond, fmt.Errorf("unknown match mode %q, use %s_eq for an exact match", cond.Column[i+1:], cond.Column)
+	}
+	return cond, nil
+}
+
+// parseFilterExpr parses a filter expression and checks every leaf against
+// the schema.
+func (c *queryParamsConfig) parseFilterExpr(raw string) (data.FilterExpr, error) {
+	e, err := parseFilterExprWith(raw, c.parseCondition)
+	if err != nil {
+		return nil, err
+	}
+	return data.MapConditions(e, c.checkCondition)
+}
+
+// checkCondition rejects conditions on columns the schema does not declare
+// as filterable, resolves relative dates and coerces values to the column
+// type.
+func (c *queryParamsConfig) checkCondition(cond data.FilterCondition) (data.FilterCondition, error) {
+	if c.schema == nil {
+		if !data.ValidColumn(cond.Column) {
+			return cond, fmt.Errorf("invalid column name %q", cond.Column)
+		}
+		return c.resolveDates(cond, c.at)
+	}
+	if _, ok := c.schema.FilterColumn(cond.Column); !ok {
+		if i := strings.LastIndex(cond.Column, "_"); i > 0 {
+			if _, ok := c.schema.FilterColumn(cond.Column[:i]); ok {
+				return cond, fmt.Errorf("unknown match mode %q", cond.Column[i+1:])
+			}
+		}
+		return cond, fmt.Errorf("column %q is not filterable", cond.Column)
+	}
+	cond, err := c.resolveDates(cond, c.at)
+	if err != nil {
+		return cond, err
+	}
+	return c.schema.Coerce(cond)
+}
+
+// parseCursor decodes a keyset token. Cursors need sort keys to compare
+// against and must carry one value per key.
+func (c *queryParamsConfig) parseCursor(token string, keys []data.SortKey) (*data.Cursor, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, errors.New("cursor is empty")
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("cursor requires a sort")
+	}
+	cursor, err := data.DecodeCursor(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(cursor.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: expected %d values, got %d", data.ErrInvalidCursor, len(keys), len(cursor.Values))
+	}
+	if c.schema != nil {
+		return c.schema.CoerceCursor(keys, cursor)
+	}
+	return cursor, nil
+}
+
+// parseGroupKey parses a group_by entry such as "status" or
+// "completed_at:day" and checks it against the schema.
+func (c *queryParamsConfig) parseGroupKey(raw string) (data.GroupKey, error) {
+	column, trunc, _ := strings.Cut(strings.TrimSpace(raw), ":")
+	group := data.GroupKey{Column: strings.TrimSpace(column), Trunc: strings.ToLower(strings.TrimSpace(trunc))}
+	if group.Column == "" {
+		return group, errors.New("missing column")
+	}
+	if group.Trunc != "" && !slices.Contains(data.TruncUnits, group.Trunc) {
+		return group, fmt.Errorf("unknown unit %q, expected one of %s", trunc, strings.Join(data.TruncUnits, ", "))
+	}
+	if c.schema != nil {
+		return group, c.schema.CheckGroup(group)
+	}
+	if !data.ValidColumn(group.Column) {
+		return group, fmt.Errorf("invalid column name %q", group.Column)
+	}
+	return group, nil
+}
+
+// parseAggregate parses an agg entry such as "count", "count:id" or
+// "sum:duration" and checks it against the schema.
+func (c *queryParamsConfig) parseAggregate(raw string) (data.Aggregate, error) {
+	fn, column, _ := strings.Cut(strings.TrimSpace(raw), ":")
+	agg := data.Aggregate{Func: data.AggFunc(strings.ToLower(strings.TrimSpace(fn))), Column: strings.TrimSpace(column)}
+	switch agg.Func {
+	case data.AggCount:
+	case data.AggSum, data.AggAvg, data.AggMin, data.AggMax:
+		if agg.Column == "" {
+			return agg, fmt.Errorf("%s needs a column, as in %s:column", agg.Func, agg.Func)
+		}
+	default:
+		return agg, fmt.Errorf("unknown aggregate %q", fn)
+	}
+	if c.schema != nil {
+		return agg, c.schema.CheckAggregate(agg)
+	}
+	if agg.Column != "" && !data.ValidColumn(agg.Column) {
+		return agg, fmt.Errorf("invalid column name %q", agg.Column)
+	}
+	return agg, nil
+}
+
+// sortable reports whether column may be sorted by: a declared sortable
+// column, or any plain identifier without a schema.
+func (c *queryParamsConfig) sortable(column string) bool {
+	if c.schema == nil {
+		return data.ValidColumn(column)
+	}
+	_, ok := c.schema.SortColumn(column)
+	return ok
+}
+
+// parseFilterCondition parses a column_mode=value condition, splitting the
+// key against schema when there is one.
+func parseFilterCondition(raw string, schema *data.Schema) (data.FilterCondition, error) {
 	if raw == "" {
-		return data.FilterCondition{}, false
+		return data.FilterCondition{}, errors.New("filter is empty")
 	}
 	parts := strings.SplitN(raw, "=", 2)
 	if len(parts) != 2 {
-		return data.FilterCondition{}, false
+		return data.FilterCondition{}, errors.New("expected column_mode=value")
 	}
 	key := strings.TrimSpace(parts[0])
 	value := strings.TrimSpace(parts[1])
 	if key == "" {
-		return data.FilterCondition{}, false
+		return data.FilterCondition{}, errors.New("missing column")
 	}
 
-	column, mode := splitColumnAndMode(key)
+	column, mode, ignoreCase := splitColumnAndMode(key, schema)
 	if column == "" {
-		return data.FilterCondition{}, false
+		return data.FilterCondition{}, errors.New("missing column")
+	}
+	cond := data.FilterCondition{
+		Column:     column,
+		Mode:       mode,
+		Value:      value,
+		IgnoreCase: ignoreCase,
 	}
-	return data.FilterCondition{
-		Column: column,
-		Mode:   mode,
-		Value:  value,
-	}, true
+	switch mode {
+	case data.In, data.NotIn, data.Within:
+		if value == "" {
+			return data.FilterCondition{}, fmt.Errorf("mode %s needs at least one value", mode)
+		}
+	case data.Between:
+		if len(cond.Values()) != 2 {
+			return data.FilterCondition{}, errors.New("mode Between needs two comma separated values")
+		}
+	}
+	return cond, nil
 }
 
-func splitColumnAndMode(key string) (string, data.MatchMode) {
-	lastUnderscore := strings.LastIndex(key, "_")
-	if lastUnderscore == -1 {
-		return strings.TrimSpace(key), data.Exact
+// splitColumnAndMode splits a filter key into its column and match mode.
+// With a schema the longest declared column wins, so "sign_in" is an exact
+// match on a declared sign_in column rather than In on sign. Otherwise the
+// longest known mode suffix wins, so "deleted_at_is_null" yields
+// ("deleted_at", IsNull). Keys without a known mode suffix are exact matches
+// on the whole key.
+func splitColumnAndMode(key string, schema *data.Schema) (string, data.MatchMode, bool) {
+	key = strings.TrimSpace(key)
+	if schema != nil {
+		if column, mode, ignoreCase, ok := splitDeclaredColumn(key, schema); ok {
+			return column, mode, ignoreCase
+		}
 	}
-	column := strings.TrimSpace(key[:lastUnderscore])
-	mode := key[lastUnderscore+1:]
-	if column == "" {
-		return "", data.Exact
+	for i := 0; i < len(key); i++ {
+		if key[i] != '_' {
+			continue
+		}
+		if mode, ignoreCase, ok := parseMatchMode(key[i+1:]); ok {
+			return strings.TrimSpace(key[:i]), mode, ignoreCase
+		}
+	}
+	return key, data.Exact, false
+}
+
+// splitDeclaredColumn splits key after the longest column schema declares,
+// the whole key being an exact match.
+func splitDeclaredColumn(key string, schema *data.Schema) (string, data.MatchMode, bool, bool) {
+	if _, ok := schema.Lookup(key); ok {
+		return key, data.Exact, false, true
+	}
+	for i := strings.LastIndex(key, "_"); i > 0; i = strings.LastIndex(key[:i], "_") {
+		column := strings.TrimSpace(key[:i])
+		if _, ok := schema.Lookup(column); !ok {
+			continue
+		}
+		if mode, ignoreCase, ok := parseMatchMode(key[i+1:]); ok {
+			return column, mode, ignoreCase, true
+		}
 	}
-	return column, parseMatchMode(mode)
+	return "", data.Exact, false, false
 }
 
-func parseMatchMode(mode string) data.MatchMode {
-	switch strings.ToLower(strings.TrimSpace(mode)) {
-	case "", "exact", "eq":
-		return data.Exact
+// parseMatchMode parses a mode name. Any mode prefixed with "i" ignores
+// case, as in icontains, istarts_with or ieq.
+func parseMatchMode(mode string) (data.MatchMode, bool, bool) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if m, ok := matchMode(mode); ok {
+		return m, false, true
+	}
+	if rest, found := strings.CutPrefix(mode, "i"); found {
+		if m, ok := matchMode(rest); ok {
+			return m, true, true
+		}
+	}
+	return data.Exact, false, false
+}
+
+func matchMode(mode string) (data.MatchMode, bool) {
+	switch mode {
+	case "exact", "eq":
+		return data.Exact, true
 	case "ci", "caseinsensitive", "case_insensitive", "ilike":
-		return data.CaseInsensitive
+		return data.CaseInsensitive, true
 	case "start", "prefix", "starts_with":
-		return data.Start
+		return data.Start, true
 	case "end", "suffix", "ends_with":
-		return data.End
+		return data.End, true
 	case "any", "anywhere", "contains":
-		return data.Anywhere
+		return data.Anywhere, true
+	case "ne", "neq", "not_eq":
+		return data.NotEqual, true
+	case "gt", "after":
+		return data.GreaterThan, true
+	case "gte", "ge":
+		return data.GreaterOrEqual, true
+	case "lt", "before":
+		return data.LessThan, true
+	case "lte", "le":
+		return data.LessOrEqual, true
+	case "in":
+		return data.In, true
+	case "not_in", "nin":
+		return data.NotIn, true
+	case "between":
+		return data.Between, true
+	case "is_null", "null":
+		return data.IsNull, true
+	case "not_null", "is_not_null":
+		return data.NotNull, true
+	case "within":
+		return data.Within, true
 	default:
-		return data.Exact
+		return data.Exact, false
 	}
 }
 
-func parseSort(raw string) (string, string) {
+// parseSortKey parses a single sort key such as "-completed_at:nulls_last"
+// from a comma separated sort list. A leading "-" or "+" sets the direction,
+// which an explicit ":asc"/":desc" suffix overrides.
+func parseSortKey(raw string) (data.SortKey, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
-		return "", ""
+		return data.SortKey{}, errors.New("sort key is empty")
 	}
 
 	direction := "asc"
@@ -167,37 +753,40 @@ func parseSort(raw string) (string, string) {
 		raw = strings.TrimPrefix(raw, "+")
 	}
 
-	parts := strings.SplitN(raw, ":", 2)
-	if parts == nil {
-		return "", ""
-	}
+	parts := strings.Split(raw, ":")
 	column := strings.TrimSpace(parts[0])
 	if column == "" {
-		return "", ""
+		return data.SortKey{}, errors.New("missing column")
 	}
 
-	if len(parts) == 2 {
-		dir := strings.ToLower(strings.TrimSpace(parts[1]))
-		if dir == "asc" || dir == "desc" {
-			direction = dir
+	key := data.SortKey{Column: column, Direction: direction}
+	for _, part := range parts[1:] {
+		switch modifier := strings.ToLower(strings.TrimSpace(part)); modifier {
+		case "asc", "desc":
+			key.Direction = modifier
+		case "nulls_first", "nullsfirst", "first":
+			key.Nulls = "first"
+		case "nulls_last", "nullslast", "last":
+			key.Nulls = "last"
+		default:
+			return data.SortKey{}, fmt.Errorf("unknown sort modifier %q", part)
 		}
 	}
-
-	return column, direction
+	return key, nil
 }
 
-func parseLimit(value string) (any, bool) {
+func parseLimit(value string) any {
 	value = strings.TrimSpace(value)
 	if value == "" {
-		return nil, false
+		return nil
 	}
 	if strings.EqualFold(value, "all") {
-		return "ALL", false
+		return "ALL"
 	}
 	if limit, err := strconv.ParseInt(value, 10, 64); err == nil && limit >= 0 {
-		return limit, true
+		return limit
 	}
-	return nil, false
+	return nil
 }
 
 func parseInt(value string) (int64, bool) {
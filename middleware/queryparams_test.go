@@ -1,10 +1,16 @@
 package middleware
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/tschuyebuhl/httpkit/data"
+	"github.com/tschuyebuhl/httpkit/httpx"
 )
 
 func TestParseQueryParamsFilters(t *testing.T) {
@@ -41,14 +47,32 @@ func TestParseQueryParamsSort(t *testing.T) {
 		t.Fatalf("expected params, got nil")
 	}
 
-	if params.Column != "completed_at" || params.Direction != "desc" {
+	if len(params.Keys) != 1 || params.Keys[0].Column != "completed_at" || params.Keys[0].Direction != "desc" {
 		t.Fatalf("expected sort completed_at desc, got %+v", params.Sort)
 	}
 }
 
+func TestParseQueryParamsMultiSort(t *testing.T) {
+	values := url.Values{}
+	values.Set("sort", "-completed_at:nulls_last,name:asc")
+
+	params := parseQueryParams(values)
+	if params == nil {
+		t.Fatalf("expected params, got nil")
+	}
+
+	want := []data.SortKey{
+		{Column: "completed_at", Direction: "desc", Nulls: "last"},
+		{Column: "name", Direction: "asc"},
+	}
+	if !reflect.DeepEqual(params.Keys, want) {
+		t.Fatalf("expected %+v, got %+v", want, params.Keys)
+	}
+}
+
 func TestParseQueryParamsPagination(t *testing.T) {
 	values := url.Values{}
-	values.Set("per_page", "15")
+	values.Set("limit", "15")
 	values.Set("page", "3")
 
 	params := parseQueryParams(values)
@@ -88,3 +112,621 @@ func TestParseQueryParamsLimitAll(t *testing.T) {
 		t.Fatalf("expected limit ALL, got %s", limit)
 	}
 }
+
+func TestParseQueryParamsSchema(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "name", Filterable: true},
+	)}
+
+	values := url.Values{}
+	values.Add("filter", "name_eq=Focus")
+	values.Add("filter", "user_id_eq=someone-else")
+	values.Set("sort", "name")
+
+	params, _ := cfg.parse(values)
+	if params == nil {
+		t.Fatalf("expected params, got nil")
+	}
+
+	if len(params.Conditions) != 1 || params.Conditions[0].Column != "name" {
+		t.Fatalf("expected only the name condition, got %+v", params.Conditions)
+	}
+	if len(params.Keys) != 0 {
+		t.Fatalf("expected non-sortable column to be dropped, got %+v", params.Sort)
+	}
+	if params.Schema == nil {
+		t.Fatal("expected schema to be carried on params")
+	}
+}
+
+func TestParseQueryParamsOperators(t *testing.T) {
+	values := url.Values{}
+	values.Add("filter", "score_gte=3")
+	values.Add("filter", "status_not_in=archived,deleted")
+	values.Add("filter", "completed_at_between=2024-01-01,2024-02-01")
+	values.Add("filter", "deleted_at_is_null=")
+	values.Add("filter", "created_at_between=2024-01-01")
+
+	params := parseQueryParams(values)
+	if params == nil {
+		t.Fatalf("expected params, got nil")
+	}
+
+	want := []data.FilterCondition{
+		{Column: "score", Mode: data.GreaterOrEqual, Value: "3"},
+		{Column: "status", Mode: data.NotIn, Value: "archived,deleted"},
+		{Column: "completed_at", Mode: data.Between, Value: "2024-01-01,2024-02-01"},
+		{Column: "deleted_at", Mode: data.IsNull, Value: ""},
+	}
+	if len(params.Conditions) != len(want) {
+		t.Fatalf("expected %d conditions, got %+v", len(want), params.Conditions)
+	}
+	for i, cond := range want {
+		if !reflect.DeepEqual(params.Conditions[i], cond) {
+			t.Fatalf("condition %d: expected %+v, got %+v", i, cond, params.Conditions[i])
+		}
+	}
+}
+
+func TestSplitColumnAndModeWithModeNames(t *testing.T) {
+	schema := data.NewSchema(
+		data.Column{Name: "sign", Filterable: true},
+		data.Column{Name: "sign_in", Filterable: true},
+		data.Column{Name: "is_null", Filterable: true},
+		data.Column{Name: "api_in", Filterable: true},
+		data.Column{Name: "starts_after", Filterable: true},
+		data.Column{Name: "deleted_at", Filterable: true},
+	)
+	tests := []struct {
+		key    string
+		schema *data.Schema
+		column string
+		mode   data.MatchMode
+	}{
+		{"sign_in", schema, "sign_in", data.Exact},
+		{"sign_in_in", schema, "sign_in", data.In},
+		{"sign_ne", schema, "sign", data.NotEqual},
+		{"is_null", schema, "is_null", data.Exact},
+		{"is_null_is_null", schema, "is_null", data.IsNull},
+		{"api_in", schema, "api_in", data.Exact},
+		{"api_in_not_in", schema, "api_in", data.NotIn},
+		{"starts_after", schema, "starts_after", data.Exact},
+		{"starts_after_after", schema, "starts_after", data.GreaterThan},
+		{"deleted_at_is_null", schema, "deleted_at", data.IsNull},
+		{"unknown_gt", schema, "unknown", data.GreaterThan},
+
+		// Without a schema the mode wins; an explicit mode disambiguates.
+		{"sign_in", nil, "sign", data.In},
+		{"sign_in_eq", nil, "sign_in", data.Exact},
+		{"is_null_eq", nil, "is_null", data.Exact},
+		{"api_in_in", nil, "api_in", data.In},
+		{"starts_after_lt", nil, "starts_after", data.LessThan},
+		{"deleted_at_is_null", nil, "deleted_at", data.IsNull},
+	}
+	for _, tt := range tests {
+		column, mode, _ := splitColumnAndMode(tt.key, tt.schema)
+		if column != tt.column || mode != tt.mode {
+			t.Errorf("%s (schema %v): expected (%q, %v), got (%q, %v)", tt.key, tt.schema != nil, tt.column, tt.mode, column, mode)
+		}
+	}
+}
+
+func TestParseQueryParamsRejectsInvalidIdentifiers(t *testing.T) {
+	cfg := &queryParamsConfig{}
+	params, errs := cfg.parse(url.Values{
+		"filter":   {`name"_eq=x`, "or(name_eq=a,x y_eq=b)", "attrs->color_eq=red"},
+		"sort":     {`-"name", id`},
+		"group_by": {`status"`},
+		"agg":      {`sum:"x"`},
+	})
+	if len(errs) != 5 {
+		t.Fatalf("expected every invalid identifier to be reported, got %+v", errs)
+	}
+	if params == nil || len(params.Conditions) != 1 || params.Conditions[0].Column != "attrs->color" {
+		t.Fatalf("expected only the valid filter, got %+v", params)
+	}
+	if len(params.Keys) != 1 || params.Keys[0].Column != "id" || len(params.GroupBy) != 0 || len(params.Aggregates) != 0 {
+		t.Fatalf("unexpected sort, groups or aggregates: %+v", params)
+	}
+}
+
+func TestParseQueryParamsTypedValues(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "score", Filterable: true, Type: data.TypeInt},
+		data.Column{Name: "done", Filterable: true, Type: data.TypeBool},
+		data.Column{Name: "completed_at", Filterable: true, Type: data.TypeTime},
+		data.Column{Name: "status", Filterable: true, Type: data.TypeEnum, Enum: []string{"active", "archived"}},
+	)}
+
+	values := url.Values{}
+	values.Add("filter", "score_in=1,2")
+	values.Add("filter", "done_eq=true")
+	values.Add("filter", "completed_at_gte=2024-01-01T00:00:00Z")
+	values.Add("filter", "score_gt=lots")
+	values.Add("filter", "status_eq=deleted")
+
+	params, _ := cfg.parse(values)
+	if params == nil {
+		t.Fatalf("expected params, got nil")
+	}
+	if len(params.Conditions) != 3 {
+		t.Fatalf("expected invalid values to be dropped, got %+v", params.Conditions)
+	}
+
+	if got := params.Conditions[0].Args; !reflect.DeepEqual(got, []any{int64(1), int64(2)}) {
+		t.Fatalf("unexpected score args: %#v", got)
+	}
+	if got := params.Conditions[1].Args; !reflect.DeepEqual(got, []any{true}) {
+		t.Fatalf("unexpected done args: %#v", got)
+	}
+	want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, ok := params.Conditions[2].Args[0].(time.Time); !ok || !got.Equal(want) {
+		t.Fatalf("unexpected completed_at args: %#v", params.Conditions[2].Args)
+	}
+}
+
+func TestParseQueryParamsCursor(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "score", Sortable: true, Type: data.TypeInt},
+	)}
+
+	values := url.Values{}
+	values.Set("sort", "-score")
+	values.Set("limit", "10")
+	values.Set("after", encodeCursor(t, 7))
+
+	params, _ := cfg.parse(values)
+	if params == nil || params.After == nil {
+		t.Fatalf("expected after cursor, got %+v", params)
+	}
+	if !reflect.DeepEqual(params.After.Values, []any{int64(7)}) {
+		t.Fatalf("expected typed cursor values, got %#v", params.After.Values)
+	}
+
+	values.Set("after", encodeCursor(t, 7, 8))
+	if params, _ := cfg.parse(values); params.After != nil {
+		t.Fatalf("expected cursor with wrong arity to be dropped, got %+v", params.After)
+	}
+}
+
+func TestQueryParamsStrictRejectsInvalidParams(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called with invalid params")
+	})
+	mw := QueryParamsMiddleware(WithStrict(true), WithSchema(data.NewSchema(
+		data.Column{Name: "name", Filterable: true, Sortable: true},
+	)))
+
+	req := httptest.NewRequest(http.MethodGet, "/habits?filter=name_foo=x&filter=secret_eq=1&sort=name:sideways&limit=-1", nil)
+	rec := httptest.NewRecorder()
+	mw(handler).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != httpx.ProblemContentType {
+		t.Fatalf("expected problem content type, got %q", ct)
+	}
+
+	var problem httpx.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	var names []string
+	for _, p := range problem.InvalidParams {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"filter", "filter", "sort", "limit"}) {
+		t.Fatalf("unexpected invalid params: %+v", problem.InvalidParams)
+	}
+	if problem.InvalidParams[0].Reason != `unknown match mode "foo"` {
+		t.Fatalf("unexpected reason: %q", problem.InvalidParams[0].Reason)
+	}
+}
+
+func TestParseQueryParamsStrictUnknownModeWithoutSchema(t *testing.T) {
+	cfg := &queryParamsConfig{strict: true}
+
+	params, errs := cfg.parse(url.Values{"filter": {"name_foo=x", "or(name_eq=a,name_bar=b)", "name=x", "user_id_eq=5", "name_icontains=gym"}})
+	var rejected []string
+	for _, e := range errs {
+		rejected = append(rejected, e.Value+": "+e.Reason)
+	}
+	want := []string{
+		`name_foo=x: unknown match mode "foo", use name_foo_eq for an exact match`,
+		`or(name_eq=a,name_bar=b): at position 13: unknown match mode "bar", use name_bar_eq for an exact match`,
+	}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Fatalf("unexpected errors %q", rejected)
+	}
+	if len(params.Conditions) != 3 || params.Conditions[1].Column != "user_id" {
+		t.Fatalf("expected the explicit conditions to parse, got %+v", params.Conditions)
+	}
+
+	cfg.strict = false
+	params, errs = cfg.parse(url.Values{"filter": {"name_foo=x"}})
+	if len(errs) != 0 || params.Conditions[0].Column != "name_foo" {
+		t.Fatalf("expected lenient mode to keep an exact match, got %+v (%v)", params.Conditions, errs)
+	}
+}
+
+func TestQueryParamsLenientDropsInvalidParams(t *testing.T) {
+	var got *data.QueryParams
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = QueryParamsFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/habits?filter=name_eq=Focus&sort=name:sideways&limit=-1", nil)
+	rec := httptest.NewRecorder()
+	QueryParams(handler).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got == nil || len(got.Conditions) != 1 || len(got.Keys) != 0 || got.Limit != "ALL" {
+		t.Fatalf("expected only the valid filter, got %+v", got)
+	}
+}
+
+func TestQueryParamsLenientRejectsInvalidFilters(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("handler should not be called with an invalid filter: %s", r.URL.RawQuery)
+	})
+	mw := QueryParamsMiddleware(WithSchema(data.NewSchema(
+		data.Column{Name: "id", Type: data.TypeInt, Filterable: true},
+	)))
+
+	for _, query := range []string{
+		"filter=id_eq=nope",
+		"filter=broken",
+		"filter=secret_eq=1",
+		"filter=or(id_eq=1,id_eq=x)",
+	} {
+		rec := httptest.NewRecorder()
+		mw(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/habits?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", query, rec.Code)
+		}
+	}
+
+	brackets := QueryParamsMiddleware(WithSyntax(BracketSyntax))
+	rec := httptest.NewRecorder()
+	brackets(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/habits?filter[name][sideways]=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid bracket filter, got %d", rec.Code)
+	}
+}
+
+func TestParseQueryParamsPageLimits(t *testing.T) {
+	cfg := &queryParamsConfig{defaultLimit: 20, maxLimit: 100}
+
+	params, errs := cfg.parse(url.Values{})
+	if params == nil || params.Limit != int64(20) || len(errs) != 0 {
+		t.Fatalf("expected default limit 20, got %+v (%v)", params, errs)
+	}
+
+	params, errs = cfg.parse(url.Values{"limit": {"500"}})
+	if params.Limit != int64(100) || len(errs) != 1 {
+		t.Fatalf("expected limit clamped to 100, got %v (%v)", params.Limit, errs)
+	}
+
+	params, errs = cfg.parse(url.Values{"limit": {"all"}})
+	if params.Limit != int64(20) || len(errs) != 1 || errs[0].Reason != "ALL is not allowed" {
+		t.Fatalf("expected ALL to be rejected, got %v (%v)", params.Limit, errs)
+	}
+
+	params, _ = cfg.parse(url.Values{"page": {"3"}})
+	if params.Offset != 40 {
+		t.Fatalf("expected page to use the default limit, got offset %d", params.Offset)
+	}
+}
+
+func TestParseQueryParamsLimitAllWithDefaultOnly(t *testing.T) {
+	cfg := &queryParamsConfig{defaultLimit: 50}
+
+	params, errs := cfg.parse(url.Values{"limit": {"ALL"}})
+	if params.Limit != int64(50) || len(errs) != 1 || errs[0].Reason != "ALL is not allowed" {
+		t.Fatalf("expected ALL to be rejected, got %v (%v)", params.Limit, errs)
+	}
+
+	allow := true
+	cfg.allowAll = &allow
+	params, errs = cfg.parse(url.Values{"limit": {"ALL"}})
+	if params.Limit != "ALL" || len(errs) != 0 {
+		t.Fatalf("expected WithAllowAll to accept ALL, got %v (%v)", params.Limit, errs)
+	}
+}
+
+func TestQueryParamsLimitHeaders(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mw := QueryParamsMiddleware(WithDefaultLimit(25), WithMaxLimit(50))
+
+	req := httptest.NewRequest(http.MethodGet, "/habits?limit=80", nil)
+	rec := httptest.NewRecorder()
+	mw(handler).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Page-Limit"); got != "50" {
+		t.Fatalf("expected X-Page-Limit 50, got %q", got)
+	}
+	if got := rec.Header().Get("X-Page-Max-Limit"); got != "50" {
+		t.Fatalf("expected X-Page-Max-Limit 50, got %q", got)
+	}
+}
+
+func TestQueryParamsRouteScoped(t *testing.T) {
+	var got *data.QueryParams
+	capture := func(w http.ResponseWriter, r *http.Request) {
+		got = QueryParamsFromContext(r.Context())
+	}
+	habits := data.NewSchema(data.Column{Name: "name", Filterable: true})
+
+	mux := http.NewServeMux()
+	httpx.Register(mux, httpx.Routes(
+		httpx.Route{Pattern: "GET /api/habits", Handler: capture, Use: []httpx.Middleware{
+			QueryParamsMiddleware(WithSchema(habits), WithMaxLimit(10)),
+		}},
+		httpx.Route{Pattern: "GET /assets/", Handler: capture},
+	))
+	handler := QueryParamsDefaults(WithStrict(true), WithDefaultLimit(5))(mux)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/habits?filter=name_eq=run", nil))
+	if rec.Code != http.StatusOK || got == nil || got.Schema != habits || got.Limit != int64(5) {
+		t.Fatalf("unexpected route params %d %+v", rec.Code, got)
+	}
+	if max := rec.Header().Get("X-Page-Max-Limit"); max != "10" {
+		t.Fatalf("expected the route limit, got %q", max)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/habits?filter=secret_eq=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the default strictness to apply to the route, got %d", rec.Code)
+	}
+
+	got = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/app.js?v=3&filter=bogus", nil))
+	if rec.Code != http.StatusOK || got != nil {
+		t.Fatalf("expected assets to be left alone, got %d %+v", rec.Code, got)
+	}
+}
+
+func TestQueryParamsRouteOverridesGlobal(t *testing.T) {
+	var got *data.QueryParams
+	handler := QueryParamsMiddleware(WithDefaultLimit(5))(
+		QueryParamsMiddleware(WithDefaultLimit(20))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = QueryParamsFromContext(r.Context())
+		})),
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/logs?sort=name", nil))
+	if got == nil || got.Limit != int64(20) || len(got.Keys) != 1 {
+		t.Fatalf("expected the route params to win, got %+v", got)
+	}
+}
+
+func TestParseQueryParamsFields(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "id"},
+		data.Column{Name: "name"},
+	)}
+
+	params, errs := cfg.parse(url.Values{"fields": {"id, name,password_hash,id"}})
+	if params == nil || !reflect.DeepEqual(params.Fields, []string{"id", "name"}) {
+		t.Fatalf("expected declared fields only, got %+v", params)
+	}
+	if len(errs) != 1 || errs[0].Value != "password_hash" {
+		t.Fatalf("expected undeclared field to be reported, got %v", errs)
+	}
+
+	params, errs = (&queryParamsConfig{}).parse(url.Values{"fields": {`id,habits.name,name"--`}})
+	if params == nil || !reflect.DeepEqual(params.Fields, []string{"id", "habits.name"}) {
+		t.Fatalf("expected identifiers only without a schema, got %+v", params)
+	}
+	if len(errs) != 1 || errs[0].Value != `name"--` {
+		t.Fatalf("expected the invalid field to be reported, got %v", errs)
+	}
+}
+
+func TestParseQueryParamsSearch(t *testing.T) {
+	params := parseQueryParams(url.Values{"q": {`  "morning run" -gym `}})
+	if params == nil || params.Search != `"morning run" -gym` {
+		t.Fatalf("expected search query, got %+v", params)
+	}
+}
+
+func TestParseQueryParamsJSONPaths(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "attrs.color", Filterable: true},
+		data.Column{Name: "attrs.difficulty", Filterable: true, Sortable: true, Type: data.TypeInt},
+	)}
+	values := url.Values{
+		"filter": {"attrs.color_eq=red", "attrs->difficulty_gte=3", "attrs->secret_eq=x"},
+		"sort":   {"-attrs->difficulty"},
+	}
+
+	params, errs := cfg.parse(values)
+	if len(errs) != 1 || errs[0].Value != "attrs->secret_eq=x" {
+		t.Fatalf("expected only the undeclared key to be rejected, got %v", errs)
+	}
+	if len(params.Conditions) != 2 || params.Conditions[1].Column != "attrs->difficulty" || params.Conditions[1].Args[0] != int64(3) {
+		t.Fatalf("unexpected conditions: %+v", params.Conditions)
+	}
+	if len(params.Keys) != 1 || params.Keys[0].Column != "attrs->difficulty" || params.Keys[0].Direction != "desc" {
+		t.Fatalf("unexpected sort: %+v", params.Keys)
+	}
+}
+
+func TestQueryParamsEncodeRoundTrip(t *testing.T) {
+	params := data.NewQuery().
+		Where("name", data.Anywhere, "gym").
+		Where("status", data.NotIn, "archived", "deleted").
+		Where("score", data.Between, 1, 5).
+		Where("deleted_at", data.NotNull).
+		WhereExpr(data.AndExpr{
+			data.FilterCondition{Column: "note", Mode: data.Exact, Value: `say "hi", (twice)`},
+			data.OrExpr{data.FilterCondition{Column: "id", Mode: data.GreaterThan, Value: "7"}},
+		}).
+		OrderDesc("created_at").
+		OrderAsc("name").
+		Fields("id", "name").
+		Search("morning run").
+		Limit(20).
+		After(data.Cursor{Values: []any{"2024-05-01T00:00:00Z", "42"}}).
+		Params()
+	// contains ignores case unless WithCaseSensitivePatterns is given.
+	params.Conditions[0].IgnoreCase = true
+
+	values, err := params.Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got := parseQueryParams(values)
+	if !reflect.DeepEqual(got, params) {
+		t.Fatalf("round trip changed params\n got: %#v\nwant: %#v", got, params)
+	}
+}
+
+func TestQueryParamsEncodeRoundTripTypedCursor(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "created_at", Type: data.TypeTime, Sortable: true},
+		data.Column{Name: "id", Type: data.TypeInt, Sortable: true},
+		data.Column{Name: "done", Type: data.TypeBool, Sortable: true},
+	)}
+	createdAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	values, err := data.NewQuery().
+		OrderDesc("created_at").
+		OrderAsc("id").
+		OrderAsc("done").
+		After(data.Cursor{Values: []any{createdAt, int64(42), true}}).
+		Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	got, errs := cfg.parse(values)
+	if len(errs) != 0 || got.After == nil {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if at, ok := got.After.Values[0].(time.Time); !ok || !at.Equal(createdAt) {
+		t.Fatalf("expected created_at %v, got %#v", createdAt, got.After.Values[0])
+	}
+	if got.After.Values[1] != int64(42) || got.After.Values[2] != true {
+		t.Fatalf("expected typed cursor values, got %#v", got.After.Values)
+	}
+
+	// Without a schema the types are unknown and numbers stay strings.
+	if got := parseQueryParams(values); got.After.Values[1] != "42" {
+		t.Fatalf("expected string cursor value, got %#v", got.After.Values[1])
+	}
+}
+
+func TestParseQueryParamsAggregates(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "status", Groupable: true},
+		data.Column{Name: "day", Source: "completed_at", Type: data.TypeTime, Groupable: true},
+		data.Column{Name: "duration", Type: data.TypeInt, Aggregatable: true},
+		data.Column{Name: "note", Aggregatable: true},
+	)}
+
+	params, errs := cfg.parse(url.Values{
+		"group_by": {"day:day,status,note,status:week"},
+		"agg":      {"count,sum:duration,avg:note,median:duration,max"},
+		"sort":     {"-count,day,duration"},
+	})
+	wantGroups := []data.GroupKey{{Column: "day", Trunc: "day"}, {Column: "status"}}
+	wantAggs := []data.Aggregate{{Func: data.AggCount}, {Func: data.AggSum, Column: "duration"}}
+	if !reflect.DeepEqual(params.GroupBy, wantGroups) || !reflect.DeepEqual(params.Aggregates, wantAggs) {
+		t.Fatalf("unexpected groups %+v and aggregates %+v", params.GroupBy, params.Aggregates)
+	}
+	if len(params.Keys) != 2 || params.Keys[0].Column != "count" || params.Keys[1].Column != "day" {
+		t.Fatalf("expected sorting on result columns only, got %+v", params.Keys)
+	}
+
+	var rejected []string
+	for _, e := range errs {
+		rejected = append(rejected, e.Name+":"+e.Value)
+	}
+	want := []string{"group_by:note", "group_by:status:week", "agg:avg:note", "agg:median:duration", "agg:max", "sort:duration"}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Fatalf("unexpected errors %v", rejected)
+	}
+
+	params, errs = cfg.parse(url.Values{"group_by": {"status"}, "sort": {"status"}, "after": {encodeCursor(t, "done")}})
+	if params.After != nil || len(errs) != 1 || errs[0].Name != "after" {
+		t.Fatalf("expected the cursor to be rejected, got %+v (%v)", params.After, errs)
+	}
+
+	encoded, err := data.NewQuery().GroupByTrunc("day", "day").Aggregate(data.AggSum, "duration").OrderDesc("sum_duration").Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if got, _ := cfg.parse(encoded); !reflect.DeepEqual(got.GroupBy, wantGroups[:1]) || !reflect.DeepEqual(got.Aggregates, wantAggs[1:]) || len(got.Keys) != 1 {
+		t.Fatalf("encoded aggregates did not round trip: %+v", got)
+	}
+}
+
+func TestParseQueryParamsIgnoreCase(t *testing.T) {
+	cfg := &queryParamsConfig{schema: data.NewSchema(
+		data.Column{Name: "name", Filterable: true},
+		data.Column{Name: "score", Filterable: true, Type: data.TypeInt},
+	)}
+
+	params, errs := cfg.parse(url.Values{"filter": {
+		"name_icontains=Gym",
+		"name_istarts_with=mor",
+		"name_ends_with=run",
+		"name_ci=read",
+		"score_ieq=3",
+	}})
+	want := []data.FilterCondition{
+		{Column: "name", Mode: data.Anywhere, Value: "Gym", IgnoreCase: true},
+		{Column: "name", Mode: data.Start, Value: "mor", IgnoreCase: true},
+		{Column: "name", Mode: data.End, Value: "run", IgnoreCase: true},
+		{Column: "name", Mode: data.CaseInsensitive, Value: "read"},
+	}
+	if !reflect.DeepEqual(params.Conditions, want) {
+		t.Fatalf("unexpected conditions %+v", params.Conditions)
+	}
+	if len(errs) != 1 || errs[0].Value != "score_ieq=3" {
+		t.Fatalf("expected ignoring case on a typed column to be rejected, got %+v", errs)
+	}
+
+	cfg.caseSensitivePatterns = true
+	params, _ = cfg.parse(url.Values{"filter": {"name_icontains=Gym", "name_ends_with=run", "or(name_starts_with=mor)"}})
+	want = []data.FilterCondition{
+		{Column: "name", Mode: data.Anywhere, Value: "Gym", IgnoreCase: true},
+		{Column: "name", Mode: data.End, Value: "run"},
+	}
+	if !reflect.DeepEqual(params.Conditions, want) {
+		t.Fatalf("unexpected case-sensitive conditions %+v", params.Conditions)
+	}
+	wantExpr := data.OrExpr{data.FilterCondition{Column: "name", Mode: data.Start, Value: "mor"}}
+	if len(params.Exprs) != 1 || !reflect.DeepEqual(params.Exprs[0], wantExpr) {
+		t.Fatalf("unexpected case-sensitive expressions %+v", params.Exprs)
+	}
+}
+
+func TestParseQueryParamsCursorWithRankedSearch(t *testing.T) {
+	schema := data.NewSchema(data.Column{Name: "id", Sortable: true}).
+		WithSearch(data.Search{Column: "search_vector", Rank: true})
+	cfg := &queryParamsConfig{schema: schema}
+
+	params, errs := cfg.parse(url.Values{"q": {"gym"}, "sort": {"id"}, "after": {encodeCursor(t, "7")}})
+	if params.After != nil || len(errs) != 1 || errs[0].Reason != "cursors cannot be combined with ranked search" {
+		t.Fatalf("expected the cursor to be rejected, got %+v (%v)", params.After, errs)
+	}
+	if params, errs = cfg.parse(url.Values{"sort": {"id"}, "after": {encodeCursor(t, "7")}}); params.After == nil || len(errs) != 0 {
+		t.Fatalf("expected a cursor without search to parse, got %v", errs)
+	}
+}
+
+func encodeCursor(t *testing.T, values ...any) string {
+	t.Helper()
+	token, err := data.Cursor{Values: values}.Encode()
+	if err != nil {
+		t.Fatalf("encode cursor: %v", err)
+	}
+	return token
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/tschuyebuhl/httpkit/httpx"
+)
+
+type keycloakClaimsKey struct{}
+
+// KeycloakClaims are the role and group claims of a Keycloak token. The
+// Keycloak middleware stores them in the request context before running the
+// token mapper.
+type KeycloakClaims struct {
+	RealmRoles  []string            // realm_access.roles
+	ClientRoles map[string][]string // resource_access.<client>.roles
+	Groups      []string            // groups, when a group mapper is configured
+}
+
+// HasRealmRole reports whether the token carries the realm role.
+func (c *KeycloakClaims) HasRealmRole(role string) bool {
+	return c != nil && slices.Contains(c.RealmRoles, role)
+}
+
+// HasClientRole reports whether the token carries role for client.
+func (c *KeycloakClaims) HasClientRole(client, role string) bool {
+	return c != nil && slices.Contains(c.ClientRoles[client], role)
+}
+
+// HasRole reports whether the token carries role, a realm role name or
+// "client:role" for a client role. Realm roles are checked first, so realm
+// role names containing a colon still match.
+func (c *KeycloakClaims) HasRole(role string) bool {
+	if c.HasRealmRole(role) {
+		return true
+	}
+	client, name, ok := strings.Cut(role, ":")
+	return ok && c.HasClientRole(client, name)
+}
+
+// InGroup reports whether the token lists group, e.g. "/admins".
+func (c *KeycloakClaims) InGroup(group string) bool {
+	return c != nil && slices.Contains(c.Groups, group)
+}
+
+func WithKeycloakClaims(ctx context.Context, claims *KeycloakClaims) context.Context {
+	return context.WithValue(ctx, keycloakClaimsKey{}, claims)
+}
+
+func KeycloakClaimsFromContext(ctx context.Context) (*KeycloakClaims, bool) {
+	claims, ok := ctx.Value(keycloakClaimsKey{}).(*KeycloakClaims)
+	return claims, ok && claims != nil
+}
+
+// parseKeycloakClaims reads the role and group claims leniently: a claim
+// with an unexpected shape is left empty rather than failing the request, so
+// only routes checking that claim are affected.
+func parseKeycloakClaims(token *oidc.IDToken) *KeycloakClaims {
+	claims := &KeycloakClaims{ClientRoles: map[string][]string{}}
+	var raw map[string]json.RawMessage
+	if err := token.Claims(&raw); err != nil {
+		return claims
+	}
+
+	claims.RealmRoles = accessRoles(raw["realm_access"])
+	var resources map[string]json.RawMessage
+	if err := json.Unmarshal(raw["resource_access"], &resources); err == nil {
+		for client, access := range resources {
+			if roles := accessRoles(access); roles != nil {
+				claims.ClientRoles[client] = roles
+			}
+		}
+	}
+	if err := json.Unmarshal(raw["groups"], &claims.Groups); err != nil {
+		claims.Groups = nil
+	}
+	return claims
+}
+
+// accessRoles reads {"roles": [...]}, returning nil for anything else.
+func accessRoles(raw json.RawMessage) []string {
+	var access struct {
+		Roles []string `json:"roles"`
+	}
+	if err := json.Unmarshal(raw, &access); err != nil {
+		return nil
+	}
+	return access.Roles
+}
+
+// RequireRealmRole lets requests through when the token has the realm role
+// and answers 403 otherwise. It must run after the Keycloak middleware.
+func RequireRealmRole(role string) func(http.Handler) http.Handler {
+	return requireClaims(func(c *KeycloakClaims) string {
+		if c.HasRealmRole(role) {
+			return ""
+		}
+		return fmt.Sprintf("realm role %q is required", role)
+	})
+}
+
+// RequireClientRole lets requests through when the token has role for client.
+func RequireClientRole(client, role string) func(http.Handler) http.Handler {
+	return requireClaims(func(c *KeycloakClaims) string {
+		if c.HasClientRole(client, role) {
+			return ""
+		}
+		return fmt.Sprintf("role %q of client %q is required", role, client)
+	})
+}
+
+// RequireAnyRole lets requests through when the token has at least one of
+// roles. Roles are named as in KeycloakClaims.HasRole.
+func RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return requireClaims(func(c *KeycloakClaims) string {
+		if slices.ContainsFunc(roles, c.HasRole) {
+			return ""
+		}
+		return "one of the roles " + quoteRoles(roles) + " is required"
+	})
+}
+
+// RequireAllRoles lets requests through when the token has every one of
+// roles. The 403 body lists the missing ones.
+func RequireAllRoles(roles ...string) func(http.Handler) http.Handler {
+	return requireClaims(func(c *KeycloakClaims) string {
+		var missing []string
+		for _, role := range roles {
+			if !c.HasRole(role) {
+				missing = append(missing, role)
+			}
+		}
+		if len(missing) == 0 {
+			return ""
+		}
+		return "missing required roles " + quoteRoles(missing)
+	})
+}
+
+// requireClaims runs check against the claims in the context; a non-empty
+// result is the reason the request is forbidden. Requests that did not pass
+// the Keycloak middleware get a 401.
+func requireClaims(check func(*KeycloakClaims) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := KeycloakClaimsFromContext(r.Context())
+			if !ok {
+				httpx.WriteProblem(w, httpx.Problem{
+					Status:   http.StatusUnauthorized,
+					Detail:   "request is not authenticated",
+					Instance: r.URL.Path,
+				})
+				return
+			}
+			if reason := check(claims); reason != "" {
+				httpx.WriteProblem(w, httpx.Problem{
+					Title:    "Insufficient role",
+					Status:   http.StatusForbidden,
+					Detail:   reason,
+					Instance: r.URL.Path,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func quoteRoles(roles []string) string {
+	quoted := make([]string, 0, len(roles))
+	for _, role := range roles {
+		quoted = append(quoted, fmt.Sprintf("%q", role))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tschuyebuhl/httpkit/httpx"
+)
+
+func TestKeycloakSetsClaims(t *testing.T) {
+	provider, issuer, sign := newTestIssuer(t)
+	token := sign(fmt.Sprintf(`{"iss":"%s","aud":"test","sub":"user-1","exp":%d,`+
+		`"realm_access":{"roles":["user","admin"]},`+
+		`"resource_access":{"habits":{"roles":["editor"]},"account":{"roles":["view-profile"]}},`+
+		`"groups":["/staff"]}`,
+		issuer, time.Now().Add(time.Hour).Unix(),
+	))
+
+	var claims *KeycloakClaims
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		claims, ok = KeycloakClaimsFromContext(r.Context())
+		if !ok {
+			t.Fatal("expected claims in context")
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/habits", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	NewKeycloak(provider).Handler(handler).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !slices.Equal(claims.RealmRoles, []string{"user", "admin"}) {
+		t.Fatalf("unexpected realm roles %v", claims.RealmRoles)
+	}
+	if !claims.HasClientRole("habits", "editor") || claims.HasClientRole("account", "editor") {
+		t.Fatalf("unexpected client roles %v", claims.ClientRoles)
+	}
+	if !claims.InGroup("/staff") {
+		t.Fatalf("unexpected groups %v", claims.Groups)
+	}
+}
+
+func TestKeycloakToleratesMalformedClaims(t *testing.T) {
+	provider, issuer, sign := newTestIssuer(t)
+	token := sign(fmt.Sprintf(`{"iss":"%s","aud":"test","sub":"user-1","exp":%d,`+
+		`"realm_access":["admin"],"resource_access":{"habits":{"roles":"editor"},"account":{"roles":["view-profile"]}},"groups":"/staff"}`,
+		issuer, time.Now().Add(time.Hour).Unix(),
+	))
+
+	var claims *KeycloakClaims
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ = KeycloakClaimsFromContext(r.Context())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/habits", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	NewKeycloak(provider).Handler(RequireClientRole("account", "view-profile")(handler)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(claims.RealmRoles) != 0 || len(claims.Groups) != 0 || claims.HasClientRole("habits", "editor") {
+		t.Fatalf("expected malformed claims to be left empty, got %+v", claims)
+	}
+
+	rec = httptest.NewRecorder()
+	NewKeycloak(provider).Handler(RequireRealmRole("admin")(handler)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", rec.Code)
+	}
+}
+
+func TestRequireRoles(t *testing.T) {
+	claims := &KeycloakClaims{
+		RealmRoles:  []string{"user"},
+		ClientRoles: map[string][]string{"habits": {"editor"}},
+	}
+
+	tests := []struct {
+		name   string
+		mw     func(http.Handler) http.Handler
+		status int
+		detail string
+	}{
+		{"realm role", RequireRealmRole("user"), http.StatusOK, ""},
+		{"missing realm role", RequireRealmRole("admin"), http.StatusForbidden, `realm role "admin" is required`},
+		{"client role", RequireClientRole("habits", "editor"), http.StatusOK, ""},
+		{"client role of other client", RequireClientRole("billing", "editor"), http.StatusForbidden, `role "editor" of client "billing" is required`},
+		{"any role", RequireAnyRole("admin", "habits:editor"), http.StatusOK, ""},
+		{"none of the roles", RequireAnyRole("admin", "habits:admin"), http.StatusForbidden, `one of the roles "admin", "habits:admin" is required`},
+		{"all roles", RequireAllRoles("user", "habits:editor"), http.StatusOK, ""},
+		{"missing some roles", RequireAllRoles("user", "admin", "habits:admin"), http.StatusForbidden, `missing required roles "admin", "habits:admin"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/habits", nil)
+			req = req.WithContext(WithKeycloakClaims(context.Background(), claims))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.status == http.StatusOK {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != httpx.ProblemContentType {
+				t.Fatalf("expected problem content type, got %q", ct)
+			}
+			var problem httpx.Problem
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Detail != tt.detail {
+				t.Fatalf("expected detail %q, got %q", tt.detail, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestRequireRolesWithoutAuth(t *testing.T) {
+	handler := RequireRealmRole("user")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called without claims")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/habits", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "not authenticated") {
+		t.Fatalf("unexpected body %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/tschuyebuhl/httpkit/httpx"
+)
+
+// Syntax rewrites a query string convention into the default one the
+// middleware parses: filter=column_mode=value, sort, limit, offset, page,
+// after, before, fields and q. Params it cannot translate are reported as
+// invalid.
+type Syntax func(values url.Values) (url.Values, []httpx.InvalidParam)
+
+// WithSyntax sets the query string convention, see BracketSyntax.
+func WithSyntax(syntax Syntax) QueryParamsOption {
+	return func(c *queryParamsConfig) {
+		if syntax != nil {
+			c.syntax = syntax
+		}
+	}
+}
+
+// bracketPage maps page[...] members to the default pagination params.
+var bracketPage = map[string]string{
+	"size":   "limit",
+	"limit":  "limit",
+	"number": "page",
+	"offset": "offset",
+	"after":  "after",
+	"cursor": "after",
+	"before": "before",
+}
+
+// BracketSyntax accepts the JSON:API style bracket params many frontend data
+// grids send, next to the default ones:
+//
+//	filter[name][contains]=gym  -> filter=name_contains=gym
+//	filter[status]=active       -> filter=status_eq=active
+//	page[size]=20&page[number]=2 -> limit=20&page=2
+//	fields[habits]=id,name      -> fields=id,name
+//
+// Any mode name accepted in the default syntax can be used as the operator.
+func BracketSyntax(values url.Values) (url.Values, []httpx.InvalidParam) {
+	out := make(url.Values, len(values))
+	var errs paramErrors
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		name, path, err := splitBrackets(key)
+		if err != nil {
+			errs.add(key, strings.Join(values[key], ","), err)
+			continue
+		}
+		if len(path) == 0 {
+			out[key] = append(out[key], values[key]...)
+			continue
+		}
+
+		switch name {
+		case "filter":
+			if len(path) > 2 || path[0] == "" {
+				errs.add(key, strings.Join(values[key], ","), errors.New("expected filter[column] or filter[column][mode]"))
+				continue
+			}
+			mode := "eq"
+			if len(path) == 2 {
+				mode = path[1]
+			}
+			if _, _, ok := parseMatchMode(mode); !ok {
+				errs.add(key, strings.Join(values[key], ","), fmt.Errorf("unknown match mode %q", mode))
+				continue
+			}
+			for _, value := range values[key] {
+				out.Add("filter", path[0]+"_"+mode+"="+value)
+			}
+		case "page":
+			param, ok := bracketPage[path[0]]
+			if !ok || len(path) > 1 {
+				errs.add(key, strings.Join(values[key], ","), fmt.Errorf("unknown page parameter %q", strings.Join(path, "][")))
+				continue
+			}
+			out[param] = append(out[param], values[key]...)
+		case "fields":
+			if len(path) > 1 {
+				errs.add(key, strings.Join(values[key], ","), errors.New("expected fields[type]"))
+				continue
+			}
+			out["fields"] = append(out["fields"], values[key]...)
+		default:
+			out[key] = append(out[key], values[key]...)
+		}
+	}
+	return out, errs
+}
+
+// splitBrackets splits "filter[name][contains]" into "filter" and
+// ["name", "contains"]. Keys without brackets have an empty path.
+func splitBrackets(key string) (string, []string, error) {
+	i := strings.IndexByte(key, '[')
+	if i < 0 {
+		if strings.IndexByte(key, ']') >= 0 {
+			return "", nil, errors.New("unbalanced brackets")
+		}
+		return key, nil, nil
+	}
+
+	name, rest := key[:i], key[i:]
+	var path []string
+	for rest != "" {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 || strings.IndexByte(rest[1:end], '[') >= 0 {
+			return "", nil, errors.New("unbalanced brackets")
+		}
+		path = append(path, strings.TrimSpace(rest[1:end]))
+		rest = rest[end+1:]
+	}
+	return name, path, nil
+}
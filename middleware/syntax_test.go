@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/tschuyebuhl/httpkit/data"
+)
+
+func TestBracketSyntaxMatchesDefaultSyntax(t *testing.T) {
+	bracket, err := url.ParseQuery("filter[name][contains]=gym&filter[status]=active&sort=-created_at&page[size]=20&page[number]=2&fields[habits]=id,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := url.ParseQuery("filter=name_contains=gym&filter=status_eq=active&sort=-created_at&limit=20&page=2&fields=id,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, errs := (&queryParamsConfig{syntax: BracketSyntax}).parse(bracket)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := parseQueryParams(plain)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("bracket params differ:\n got %+v\nwant %+v", got, want)
+	}
+	if got.Offset != 20 || got.Conditions[0].Mode != data.Anywhere {
+		t.Fatalf("unexpected params: %+v", got)
+	}
+}
+
+func TestBracketSyntaxKeepsDefaultParams(t *testing.T) {
+	values := url.Values{"filter": {"name_eq=Focus"}, "limit": {"5"}}
+
+	params, errs := (&queryParamsConfig{syntax: BracketSyntax}).parse(values)
+	if len(errs) != 0 || len(params.Conditions) != 1 || params.Limit != int64(5) {
+		t.Fatalf("expected default syntax to keep working, got %+v (%v)", params, errs)
+	}
+}
+
+func TestBracketSyntaxInvalidParams(t *testing.T) {
+	values, err := url.ParseQuery("filter[name][foo]=x&page[color]=red&filter[name=x&filter[a][b][c]=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, errs := (&queryParamsConfig{syntax: BracketSyntax}).parse(values)
+	var names []string
+	for _, e := range errs {
+		names = append(names, e.Name)
+	}
+	want := []string{"filter[a][b][c]", "filter[name", "filter[name][foo]", "page[color]"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected invalid params: %+v", errs)
+	}
+}